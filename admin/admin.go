@@ -0,0 +1,249 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/hyprmcp/mcp-gateway/config"
+	"github.com/hyprmcp/mcp-gateway/log"
+	"github.com/hyprmcp/mcp-gateway/oauth"
+	"github.com/hyprmcp/mcp-gateway/proxy"
+	"github.com/hyprmcp/mcp-gateway/webhook"
+	"github.com/opencontainers/go-digest"
+)
+
+// Runtime gives the admin API access to the current state of the gateway.
+type Runtime interface {
+	// Config returns the currently active configuration.
+	Config() *config.Config
+	// OAuthManagers returns the currently active OAuth managers, one for each host.
+	OAuthManagers() []*oauth.Manager
+	// Sessions returns the registry of proxied MCP sessions.
+	Sessions() *proxy.SessionRegistry
+	// ToolPins returns the store of pinned tool definitions.
+	ToolPins() *proxy.ToolPinStore
+	// Approvals returns the store of tools/call requests that wait for an approval.
+	Approvals() *proxy.ApprovalStore
+	// Reload re-reads the configuration file and reconfigures the gateway.
+	Reload(ctx context.Context) error
+}
+
+type Route struct {
+	Host                  string `json:"host"`
+	Path                  string `json:"path"`
+	Upstream              string `json:"upstream"`
+	AuthenticationEnabled bool   `json:"authenticationEnabled"`
+	TelemetryEnabled      bool   `json:"telemetryEnabled"`
+	WebhookEnabled        bool   `json:"webhookEnabled"`
+}
+
+// NewHandler creates the handler for the admin API.
+//
+// All requests must be authenticated with the bearer token configured in admin.token.
+func NewHandler(rt Runtime) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /config", func(w http.ResponseWriter, r *http.Request) {
+		if s, err := rt.Config().YAMLString(); err != nil {
+			log.Get(r.Context()).Error(err, "failed to marshal config")
+			http.Error(w, "failed to marshal config", http.StatusInternalServerError)
+		} else {
+			w.Header().Set("Content-Type", "application/yaml")
+			_, _ = w.Write([]byte(s))
+		}
+	})
+
+	mux.HandleFunc("POST /config/reload", func(w http.ResponseWriter, r *http.Request) {
+		if err := rt.Reload(r.Context()); err != nil {
+			log.Get(r.Context()).Error(err, "admin config reload failed")
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+
+	mux.HandleFunc("GET /routes", func(w http.ResponseWriter, r *http.Request) {
+		routes := []Route{}
+		for _, hostConfig := range rt.Config().VirtualHostConfigs() {
+			for _, proxyConfig := range hostConfig.Proxy {
+				route := Route{
+					Host:                  hostConfig.Host.Host,
+					Path:                  proxyConfig.Path,
+					AuthenticationEnabled: proxyConfig.Authentication.Enabled,
+					TelemetryEnabled:      proxyConfig.Telemetry.Enabled,
+					WebhookEnabled:        proxyConfig.Webhook != nil,
+				}
+				if proxyConfig.Http != nil && proxyConfig.Http.Url != nil {
+					route.Upstream = proxyConfig.Http.Url.String()
+				}
+				routes = append(routes, route)
+			}
+		}
+		writeJSON(w, r, routes)
+	})
+
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, r, jwksStatus(r.Context(), rt))
+	})
+
+	mux.HandleFunc("POST /jwks/refresh", func(w http.ResponseWriter, r *http.Request) {
+		var errs []error
+		for _, mgr := range rt.OAuthManagers() {
+			errs = append(errs, mgr.RefreshJWKS(r.Context()))
+		}
+		if err := errors.Join(errs...); err != nil {
+			log.Get(r.Context()).Error(err, "admin jwks refresh failed")
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		} else {
+			writeJSON(w, r, jwksStatus(r.Context(), rt))
+		}
+	})
+
+	mux.HandleFunc("GET /clients", func(w http.ResponseWriter, r *http.Request) {
+		if clients, err := clients(r.Context(), rt, 0, false); err != nil {
+			log.Get(r.Context()).Error(err, "admin client listing failed")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		} else {
+			writeJSON(w, r, clients)
+		}
+	})
+
+	mux.HandleFunc("POST /clients/prune", func(w http.ResponseWriter, r *http.Request) {
+		var unusedFor time.Duration
+		if s := r.URL.Query().Get("unusedFor"); s != "" {
+			if d, err := time.ParseDuration(s); err != nil || d <= 0 {
+				http.Error(w, "unusedFor must be a positive duration", http.StatusBadRequest)
+				return
+			} else {
+				unusedFor = d
+			}
+		}
+
+		if pruned, err := clients(r.Context(), rt, unusedFor, true); err != nil {
+			log.Get(r.Context()).Error(err, "admin client pruning failed")
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		} else {
+			writeJSON(w, r, pruned)
+		}
+	})
+
+	mux.HandleFunc("GET /sessions", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, r, rt.Sessions().List())
+	})
+
+	mux.HandleFunc("DELETE /sessions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if err := rt.Sessions().Kill(r.Context(), r.PathValue("id")); errors.Is(err, proxy.ErrSessionNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else if err != nil {
+			log.Get(r.Context()).Error(err, "admin session kill failed")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+
+	mux.HandleFunc("GET /tools/pins", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, r, rt.ToolPins().Pins())
+	})
+
+	mux.HandleFunc("GET /tools/changes", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, r, rt.ToolPins().Pending())
+	})
+
+	mux.HandleFunc("POST /tools/changes/approve", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if d, err := digest.Parse(q.Get("digest")); err != nil {
+			http.Error(w, "digest is invalid", http.StatusBadRequest)
+		} else if err := rt.ToolPins().Approve(q.Get("upstream"), q.Get("tool"), d); errors.Is(err, proxy.ErrToolChangeNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else if err != nil {
+			log.Get(r.Context()).Error(err, "admin tool change approval failed")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		} else {
+			log.Get(r.Context()).Info("tool change approved", "upstream", q.Get("upstream"), "tool", q.Get("tool"), "digest", d)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+
+	mux.HandleFunc("GET /approvals", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, r, rt.Approvals().Pending())
+	})
+
+	mux.Handle("GET /debug/vars", expvar.Handler())
+
+	// the gateway doesn't queue webhook deliveries, it reports the number of deliveries that are in flight instead
+	mux.HandleFunc("GET /webhooks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, r, map[string]any{"inFlight": webhook.InFlight()})
+	})
+
+	return authenticate(rt, mux)
+}
+
+// jwksStatus returns the status of all JWKS of all hosts. JWKS that are shared by multiple hosts are only included once.
+func jwksStatus(ctx context.Context, rt Runtime) []oauth.JWKSStatus {
+	result := []oauth.JWKSStatus{}
+	for _, mgr := range rt.OAuthManagers() {
+		for _, status := range mgr.JWKSStatus(ctx) {
+			if !slices.ContainsFunc(result, func(s oauth.JWKSStatus) bool { return s.URL == status.URL }) {
+				result = append(result, status)
+			}
+		}
+	}
+	return result
+}
+
+// clients lists or prunes the dynamically registered clients of all hosts. Authorization servers that are shared by
+// multiple hosts are only included once.
+func clients(ctx context.Context, rt Runtime, unusedFor time.Duration, prune bool) ([]oauth.ClientStatus, error) {
+	result := []oauth.ClientStatus{}
+	var errs []error
+	for _, mgr := range rt.OAuthManagers() {
+		var clients []oauth.ClientStatus
+		var err error
+		if prune {
+			clients, err = mgr.PruneClients(ctx, unusedFor)
+		} else {
+			clients, err = mgr.Clients(ctx)
+		}
+		errs = append(errs, err)
+
+		for _, client := range clients {
+			if !slices.ContainsFunc(result, func(c oauth.ClientStatus) bool {
+				return c.Server == client.Server && c.ClientID == client.ClientID
+			}) {
+				result = append(result, client)
+			}
+		}
+	}
+	return result, errors.Join(errs...)
+}
+
+func authenticate(rt Runtime, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var expected string
+		if adminConfig := rt.Config().Admin; adminConfig != nil {
+			expected = string(adminConfig.Token)
+		}
+
+		rawToken, ok := oauth.BearerToken(r)
+		if !ok || expected == "" || subtle.ConstantTimeCompare([]byte(rawToken), []byte(expected)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		} else {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Get(r.Context()).Error(err, "failed to encode admin response")
+	}
+}
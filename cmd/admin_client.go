@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const adminTokenEnv = "MCP_GATEWAY_ADMIN_TOKEN"
+
+// AdminClientOptions configures commands that use the admin API of a running gateway.
+type AdminClientOptions struct {
+	AdminURL   string
+	AdminToken string
+}
+
+func BindAdminClientOptions(cmd *cobra.Command, opts *AdminClientOptions) {
+	cmd.PersistentFlags().StringVar(&opts.AdminURL, "admin-url", "http://localhost:9001", "URL of the admin API of the gateway")
+	cmd.PersistentFlags().StringVar(&opts.AdminToken, "admin-token", "", "Token for the admin API (defaults to $"+adminTokenEnv+")")
+}
+
+// adminRequest sends a request to the admin API and decodes the JSON response into result, if it is not nil.
+func adminRequest(ctx context.Context, opts AdminClientOptions, method, path string, query url.Values, result any) error {
+	token := opts.AdminToken
+	if token == "" {
+		token = os.Getenv(adminTokenEnv)
+	}
+	if token == "" {
+		return fmt.Errorf("an admin token is required, use --admin-token or set %v", adminTokenEnv)
+	}
+
+	u, err := url.Parse(opts.AdminURL)
+	if err != nil {
+		return fmt.Errorf("invalid admin URL: %w", err)
+	}
+	u = u.JoinPath(path)
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("admin API %v %v: %v: %v", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	} else if result != nil {
+		return json.NewDecoder(resp.Body).Decode(result)
+	} else {
+		return nil
+	}
+}
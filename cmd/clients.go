@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hyprmcp/mcp-gateway/oauth"
+	"github.com/spf13/cobra"
+)
+
+func NewClientsCommand() *cobra.Command {
+	var opts AdminClientOptions
+	cmd := &cobra.Command{
+		Use:   "clients",
+		Short: "Manage dynamically registered clients of a running gateway",
+	}
+	BindAdminClientOptions(cmd, &opts)
+	cmd.AddCommand(newClientsListCommand(&opts), newClientsPruneCommand(&opts))
+	return cmd
+}
+
+func newClientsListCommand(opts *AdminClientOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:          "list",
+		Short:        "List all dynamically registered clients",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var clients []oauth.ClientStatus
+			if err := adminRequest(cmd.Context(), *opts, http.MethodGet, "/clients", nil, &clients); err != nil {
+				return err
+			}
+			return printClients(cmd.OutOrStdout(), clients)
+		},
+	}
+}
+
+type ClientsPruneOptions struct {
+	UnusedFor time.Duration
+}
+
+func newClientsPruneCommand(adminOpts *AdminClientOptions) *cobra.Command {
+	var opts ClientsPruneOptions
+	cmd := &cobra.Command{
+		Use:          "prune",
+		Short:        "Delete dynamically registered clients that have not been used for some time",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query := url.Values{}
+			if opts.UnusedFor > 0 {
+				query.Set("unusedFor", opts.UnusedFor.String())
+			}
+
+			var clients []oauth.ClientStatus
+			if err := adminRequest(cmd.Context(), *adminOpts, http.MethodPost, "/clients/prune", query, &clients); err != nil {
+				return err
+			}
+
+			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "%v client(s) deleted\n", len(clients))
+			return printClients(cmd.OutOrStdout(), clients)
+		},
+	}
+	cmd.Flags().DurationVar(&opts.UnusedFor, "unused-for", 0,
+		"Delete clients that have not been used for this long (defaults to dynamicClientRegistration.unusedClientExpiry)")
+	return cmd
+}
+
+func printClients(w io.Writer, clients []oauth.ClientStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "SERVER\tCLIENT ID\tNAME\tCREATED\tLAST USED")
+	for _, c := range clients {
+		_, _ = fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\n", c.Server, c.ClientID, c.ClientName,
+			c.CreatedAt.Format(time.RFC3339), c.LastUsedAt.Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
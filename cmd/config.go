@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/hyprmcp/mcp-gateway/config"
+	"github.com/spf13/cobra"
+)
+
+func NewConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Configuration related commands",
+	}
+	cmd.AddCommand(newConfigSchemaCommand(), newConfigMigrateCommand())
+	return cmd
+}
+
+type ConfigMigrateOptions struct {
+	Config string
+	Write  bool
+}
+
+func newConfigMigrateCommand() *cobra.Command {
+	var opts ConfigMigrateOptions
+	cmd := &cobra.Command{
+		Use:          "migrate",
+		Short:        "Rewrite a configuration file to replace deprecated fields",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigMigrate(cmd, opts)
+		},
+	}
+	cmd.Flags().StringVarP(&opts.Config, "config", "c", "config.yaml", "Path to the configuration file")
+	cmd.Flags().BoolVarP(&opts.Write, "write", "w", false, "Write the result to the configuration file instead of stdout")
+	return cmd
+}
+
+func runConfigMigrate(cmd *cobra.Command, opts ConfigMigrateOptions) error {
+	data, err := os.ReadFile(opts.Config)
+	if err != nil {
+		return fmt.Errorf("failed to read config file %s: %w", opts.Config, err)
+	}
+
+	var buf bytes.Buffer
+	changes, err := config.Migrate(bytes.NewReader(data), &buf)
+	if err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		_, _ = fmt.Fprintln(cmd.ErrOrStderr(), change)
+	}
+
+	if !opts.Write {
+		_, err := buf.WriteTo(cmd.OutOrStdout())
+		return err
+	} else if len(changes) == 0 {
+		_, _ = fmt.Fprintln(cmd.ErrOrStderr(), "no changes necessary")
+		return nil
+	} else if info, err := os.Stat(opts.Config); err != nil {
+		return err
+	} else {
+		return os.WriteFile(opts.Config, buf.Bytes(), info.Mode().Perm())
+	}
+}
+
+func newConfigSchemaCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:          "schema",
+		Short:        "Print the JSON Schema of the configuration file",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if schema, err := config.JSONSchema(); err != nil {
+				return err
+			} else {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(schema)
+			}
+		},
+	}
+}
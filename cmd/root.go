@@ -12,5 +12,6 @@ func NewRootCommand() *cobra.Command {
 		},
 	}
 	BindServeOptions(cmd, &opts)
+	cmd.AddCommand(NewValidateCommand(), NewConfigCommand(), NewClientsCommand(), NewToolsCommand(), NewRulesCommand())
 	return cmd
 }
@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hyprmcp/mcp-gateway/config"
+	"github.com/hyprmcp/mcp-gateway/jsonrpc"
+	"github.com/hyprmcp/mcp-gateway/rules"
+	"github.com/spf13/cobra"
+)
+
+func NewRulesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rules",
+		Short: "Work with the rules of a configuration file",
+	}
+	cmd.AddCommand(newRulesTestCommand())
+	return cmd
+}
+
+type RulesTestOptions struct {
+	Config   string
+	Host     string
+	Proxy    string
+	Request  string
+	Response string
+	Claims   string
+}
+
+func newRulesTestCommand() *cobra.Command {
+	var opts RulesTestOptions
+	cmd := &cobra.Command{
+		Use:   "test",
+		Short: "Apply the rules of a proxy to sample messages",
+		Long: "Apply the rules of a proxy to a sample JSON-RPC request and, optionally, its response and print the " +
+			"matching rules and the resulting messages.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRulesTest(cmd, opts)
+		},
+	}
+	cmd.Flags().StringVarP(&opts.Config, "config", "c", "config.yaml", "Path to the configuration file")
+	cmd.Flags().StringVar(&opts.Host, "host", "", "Host of the virtual host of the proxy (defaults to any host)")
+	cmd.Flags().StringVar(&opts.Proxy, "proxy", "", "Path of the proxy")
+	cmd.Flags().StringVar(&opts.Request, "request", "", "Path of a file with the JSON-RPC request (- for stdin)")
+	cmd.Flags().StringVar(&opts.Response, "response", "", "Path of a file with the JSON-RPC response")
+	cmd.Flags().StringVar(&opts.Claims, "claims", "", "Path of a file with the access token claims as JSON object")
+	_ = cmd.MarkFlagRequired("proxy")
+	_ = cmd.MarkFlagRequired("request")
+	return cmd
+}
+
+func runRulesTest(cmd *cobra.Command, opts RulesTestOptions) error {
+	cfg, err := config.ParseFile(opts.Config)
+	if err != nil {
+		return err
+	}
+
+	var proxyConfig *config.Proxy
+	for _, hostConfig := range cfg.VirtualHostConfigs() {
+		if opts.Host != "" && !strings.EqualFold(hostConfig.Host.Host, opts.Host) {
+			continue
+		}
+		for i := range hostConfig.Proxy {
+			if hostConfig.Proxy[i].Path == opts.Proxy && proxyConfig == nil {
+				proxyConfig = &hostConfig.Proxy[i]
+			}
+		}
+	}
+
+	if proxyConfig == nil {
+		return fmt.Errorf("proxy %v not found", opts.Proxy)
+	}
+
+	engine, err := rules.New(proxyConfig.Rules)
+	if err != nil {
+		return err
+	} else if engine == nil {
+		return fmt.Errorf("proxy %v has no rules", opts.Proxy)
+	}
+
+	var req *jsonrpc.Request
+	if msg, err := readJSONRPCMessage(cmd.InOrStdin(), opts.Request); err != nil {
+		return err
+	} else if r, ok := msg.(*jsonrpc.Request); !ok {
+		return fmt.Errorf("%v does not contain a JSON-RPC request", opts.Request)
+	} else {
+		req = r
+	}
+
+	var claims map[string]any
+	if opts.Claims != "" {
+		if data, err := os.ReadFile(opts.Claims); err != nil {
+			return err
+		} else if err := json.Unmarshal(data, &claims); err != nil {
+			return fmt.Errorf("failed to parse claims: %w", err)
+		}
+	}
+
+	ev, err := engine.ApplyRequest(req, claims)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if len(ev.Matched) == 0 {
+		_, _ = fmt.Fprintln(out, "matched rules: none")
+	} else {
+		_, _ = fmt.Fprintf(out, "matched rules: %v\n", strings.Join(ev.Matched, ", "))
+	}
+
+	if ev.Error != nil {
+		return printJSON(out, "response (request is not forwarded)", &jsonrpc.Response{ID: req.ID, Error: ev.Error})
+	} else if err := printJSON(out, "request", ev.Request); err != nil {
+		return err
+	} else if opts.Response == "" {
+		return nil
+	}
+
+	if msg, err := readJSONRPCMessage(cmd.InOrStdin(), opts.Response); err != nil {
+		return err
+	} else if resp, ok := msg.(*jsonrpc.Response); !ok {
+		return fmt.Errorf("%v does not contain a JSON-RPC response", opts.Response)
+	} else if newResp, err := ev.ApplyResponse(resp); err != nil {
+		return err
+	} else {
+		return printJSON(out, "response", newResp)
+	}
+}
+
+func readJSONRPCMessage(stdin io.Reader, path string) (jsonrpc.Message, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+
+	if err != nil {
+		return nil, err
+	} else if msg, err := jsonrpc.ParseMessage(data); err != nil {
+		return nil, fmt.Errorf("failed to parse %v: %w", path, err)
+	} else {
+		return msg, nil
+	}
+}
+
+func printJSON(w io.Writer, title string, v any) error {
+	if data, err := json.MarshalIndent(v, "", "  "); err != nil {
+		return err
+	} else {
+		_, err := fmt.Fprintf(w, "%v:\n%s\n", title, data)
+		return err
+	}
+}
+
+// checkRules compiles the rules of all proxies to report invalid expressions.
+func checkRules(cfg *config.Config) (issues []config.Issue) {
+	for i, hostConfig := range cfg.VirtualHostConfigs() {
+		var pathPrefix string
+		if i > 0 {
+			pathPrefix = "virtualHosts." + strconv.Itoa(i-1) + "."
+		}
+
+		for j, proxyConfig := range hostConfig.Proxy {
+			for k, rule := range proxyConfig.Rules {
+				if _, err := rules.New([]config.Rule{rule}); err != nil {
+					path := pathPrefix + "proxy." + strconv.Itoa(j) + ".rules." + strconv.Itoa(k)
+					issues = append(issues, cfg.NewIssue(config.SeverityError, path, "%v", err))
+				}
+			}
+		}
+	}
+	return issues
+}
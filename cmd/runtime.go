@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyprmcp/mcp-gateway/config"
+	"github.com/hyprmcp/mcp-gateway/log"
+	"github.com/hyprmcp/mcp-gateway/oauth"
+	"github.com/hyprmcp/mcp-gateway/proxy"
+)
+
+var (
+	configReloadsTotal        = expvar.NewInt("config_reloads_total")
+	configReloadFailuresTotal = expvar.NewInt("config_reload_failures_total")
+	configReloadDuration      = expvar.NewFloat("config_last_reload_duration_seconds")
+	configReloadTimestamp     = expvar.NewInt("config_last_reload_success_timestamp_seconds")
+)
+
+// runtime holds the state of a running gateway and implements admin.Runtime.
+type runtime struct {
+	ctx        context.Context
+	configPath string
+	handler    delegateHandler
+	sessions   *proxy.SessionRegistry
+	toolPins   *proxy.ToolPinStore
+	approvals  *proxy.ApprovalStore
+	mu         sync.Mutex
+}
+
+// reconfigure validates the given config, creates a new router for it and atomically replaces the currently active
+// router. If anything fails, the currently active router stays in place.
+func (rt *runtime) reconfigure(c *config.Config) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	start := time.Now()
+	previous := rt.handler.delegate.Load()
+	err := rt.apply(c, previous)
+
+	if previous != nil {
+		configReloadsTotal.Add(1)
+		configReloadDuration.Set(time.Since(start).Seconds())
+		if err != nil {
+			configReloadFailuresTotal.Add(1)
+		} else {
+			configReloadTimestamp.Set(time.Now().Unix())
+		}
+	}
+
+	return err
+}
+
+func (rt *runtime) apply(c *config.Config, previous *router) error {
+	log := log.Get(rt.ctx)
+
+	log.Info("Configuring server...")
+
+	var errs []error
+	issues := append(c.Check(), checkRoutes(c)...)
+	for _, issue := range append(issues, checkRules(c)...) {
+		if issue.Severity == config.SeverityError {
+			errs = append(errs, errors.New(issue.String()))
+		} else {
+			log.Info("configuration warning", "warning", issue.String())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+
+	// the tool pin store is created once at startup and shared by all routers, so its configuration can't be reloaded
+	if previous != nil {
+		for _, change := range config.Diff(previous.config, c) {
+			if change.Path == "toolPins" || strings.HasPrefix(change.Path, "toolPins.") {
+				return fmt.Errorf("toolPins can only be changed by restarting the gateway: %v", change)
+			}
+		}
+	}
+
+	var previousManagers []*oauth.Manager
+	if previous != nil {
+		previousManagers = previous.oauthManagers()
+	}
+
+	// the upstream metadata store is scoped to the router, so that entries of previous configurations are not reused
+	upstreamMetadata, err := oauth.NewUpstreamMetadataStore(c.UpstreamMetadata)
+	if err != nil {
+		return err
+	}
+
+	// authorization servers are shared between hosts with the same authorization configuration
+	var oauthManagers []*oauth.Manager
+	release := func() {
+		for _, mgr := range oauthManagers {
+			mgr.Release(previousManagers...)
+		}
+	}
+
+	for _, hostConfig := range c.VirtualHostConfigs() {
+		if mgr, err := oauth.NewManager(rt.ctx, hostConfig, upstreamMetadata, append(previousManagers, oauthManagers...)...); err != nil {
+			release()
+			return fmt.Errorf("host %v: %w", hostConfig.Host.Host, err)
+		} else {
+			oauthManagers = append(oauthManagers, mgr)
+		}
+	}
+
+	r, err := newRouter(c, oauthManagers, rt.sessions, rt.toolPins, rt.approvals)
+	if err != nil {
+		release()
+		return err
+	}
+
+	if previous != nil {
+		log.Info("Configuration changed", "changes", config.Diff(previous.config, c))
+	}
+
+	rt.handler.delegate.Store(r)
+
+	for _, mgr := range previousManagers {
+		mgr.Release(oauthManagers...)
+	}
+
+	return nil
+}
+
+func (rt *runtime) close() {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	if r := rt.handler.delegate.Load(); r != nil {
+		for _, mgr := range r.oauthManagers() {
+			mgr.Release()
+		}
+	}
+}
+
+func (rt *runtime) Config() *config.Config {
+	return rt.handler.delegate.Load().config
+}
+
+func (rt *runtime) OAuthManagers() []*oauth.Manager {
+	return rt.handler.delegate.Load().oauthManagers()
+}
+
+func (rt *runtime) Sessions() *proxy.SessionRegistry {
+	return rt.sessions
+}
+
+func (rt *runtime) ToolPins() *proxy.ToolPinStore {
+	return rt.toolPins
+}
+
+func (rt *runtime) Approvals() *proxy.ApprovalStore {
+	return rt.approvals
+}
+
+func (rt *runtime) Reload(ctx context.Context) error {
+	log.Get(ctx).Info("starting config reload", "path", rt.configPath)
+
+	if cfg, err := config.ParseFile(rt.configPath); err != nil {
+		configReloadsTotal.Add(1)
+		configReloadFailuresTotal.Add(1)
+		return err
+	} else {
+		return rt.reconfigure(cfg)
+	}
+}
@@ -4,14 +4,23 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/go-chi/cors"
 	"github.com/go-logr/stdr"
+	"github.com/hyprmcp/mcp-gateway/admin"
 	"github.com/hyprmcp/mcp-gateway/config"
 	"github.com/hyprmcp/mcp-gateway/htmlresponse"
 	"github.com/hyprmcp/mcp-gateway/log"
@@ -21,10 +30,13 @@ import (
 	"github.com/spf13/cobra"
 )
 
+const configReloadDebounce = 500 * time.Millisecond
+
 type ServeOptions struct {
 	Config        string
 	Addr          string
 	AuthProxyAddr string
+	AdminAddr     string
 	Verbosity     int
 }
 
@@ -32,6 +44,7 @@ func BindServeOptions(cmd *cobra.Command, opts *ServeOptions) {
 	cmd.Flags().StringVarP(&opts.Config, "config", "c", "config.yaml", "Path to the configuration file")
 	cmd.Flags().StringVarP(&opts.Addr, "addr", "a", ":9000", "Address to listen on")
 	cmd.Flags().StringVar(&opts.AuthProxyAddr, "auth-proxy-addr", "", "Address to listen on with the authentication server proxy (advanced feature)")
+	cmd.Flags().StringVar(&opts.AdminAddr, "admin-addr", "", "Address to listen on with the admin API (disabled if empty)")
 	cmd.Flags().IntVarP(&opts.Verbosity, "verbosity", "v", 0, "Set the logging verbosity; greater number means more logging")
 }
 
@@ -61,31 +74,57 @@ func runServe(ctx context.Context, opts ServeOptions) error {
 		}()
 	}
 
-	handler := &delegateHandler{}
+	toolPins, err := proxy.NewToolPinStore(cfg.ToolPins)
+	if err != nil {
+		return err
+	}
 
-	routerCtx, routerCancel := context.WithCancel(ctx)
-	defer func() { routerCancel() }()
+	rt := &runtime{
+		ctx:        ctx,
+		configPath: opts.Config,
+		sessions:   proxy.NewSessionRegistry(),
+		toolPins:   toolPins,
+		approvals:  proxy.NewApprovalStore(),
+	}
 
-	if h, err := newRouter(routerCtx, cfg); err != nil {
+	defer rt.close()
+
+	if err := rt.reconfigure(cfg); err != nil {
 		return err
-	} else {
-		handler.delegate = h
 	}
 
+	if opts.AdminAddr != "" {
+		if cfg.Admin == nil || cfg.Admin.Token == "" {
+			return errors.New("admin.token is required when the admin API is enabled")
+		}
+
+		go func() {
+			log.Get(ctx).Info("starting admin server", "addr", opts.AdminAddr)
+			if err := http.ListenAndServe(opts.AdminAddr, admin.NewHandler(rt)); !errors.Is(err, http.ErrServerClosed) {
+				done <- fmt.Errorf("admin serve failed: %w", err)
+			} else {
+				done <- nil
+			}
+		}()
+	}
+
+	go func() {
+		sighup := make(chan os.Signal, 1)
+		signal.Notify(sighup, syscall.SIGHUP)
+		for range sighup {
+			if err := rt.Reload(ctx); err != nil {
+				log.Get(ctx).Error(err, "failed to reload server")
+			}
+		}
+	}()
+
 	go func() {
 		err := WatchConfigChanges(
 			opts.Config,
+			cfg.Files(),
 			func(c *config.Config) {
-				newRouterCtx, newRouterCancel := context.WithCancel(ctx)
-				log.Get(ctx).Info("Reconfiguring server after config change...")
-				if h, err := newRouter(newRouterCtx, c); err != nil {
-					newRouterCancel()
+				if err := rt.reconfigure(c); err != nil {
 					log.Get(ctx).Error(err, "failed to reload server")
-				} else {
-					routerCancel()
-					routerCancel = newRouterCancel
-					routerCtx = newRouterCtx
-					handler.delegate = h
 				}
 			},
 		)
@@ -96,7 +135,7 @@ func runServe(ctx context.Context, opts ServeOptions) error {
 
 	go func() {
 		log.Get(ctx).Info("Starting server", "addr", opts.Addr)
-		if err := http.ListenAndServe(opts.Addr, cors.AllowAll().Handler(handler)); !errors.Is(err, http.ErrServerClosed) {
+		if err := http.ListenAndServe(opts.Addr, cors.AllowAll().Handler(&rt.handler)); !errors.Is(err, http.ErrServerClosed) {
 			done <- fmt.Errorf("serve failed: %w", err)
 		} else {
 			done <- nil
@@ -106,36 +145,123 @@ func runServe(ctx context.Context, opts ServeOptions) error {
 	return <-done
 }
 
-func newRouter(ctx context.Context, config *config.Config) (http.Handler, error) {
+// router dispatches requests to the handler of the host in their Host header. Requests for unknown hosts are handled
+// by the handler of the top-level configuration.
+type router struct {
+	config *config.Config
+	// hosts contains one hostRouter for each element of config.VirtualHostConfigs(), in the same order.
+	hosts []*hostRouter
+}
+
+type hostRouter struct {
+	http.Handler
+	config       *config.Config
+	oauthManager *oauth.Manager
+}
+
+func newRouter(
+	config *config.Config,
+	oauthManagers []*oauth.Manager,
+	sessions *proxy.SessionRegistry,
+	toolPins *proxy.ToolPinStore,
+	approvals *proxy.ApprovalStore,
+) (*router, error) {
+	r := &router{config: config}
+	for i, hostConfig := range config.VirtualHostConfigs() {
+		if hr, err := newHostRouter(hostConfig, oauthManagers[i], sessions, toolPins, approvals); err != nil {
+			return nil, fmt.Errorf("host %v: %w", hostConfig.Host.Host, err)
+		} else {
+			r.hosts = append(r.hosts, hr)
+		}
+	}
+	return r, nil
+}
+
+func newHostRouter(
+	config *config.Config,
+	oauthManager *oauth.Manager,
+	sessions *proxy.SessionRegistry,
+	toolPins *proxy.ToolPinStore,
+	approvals *proxy.ApprovalStore,
+) (*hostRouter, error) {
 	mux := http.NewServeMux()
 
 	htmlHandler := htmlresponse.NewHandler(config, false)
-	oauthManager, err := oauth.NewManager(ctx, config)
-	if err != nil {
-		return nil, err
-	}
 
 	if err := oauthManager.Register(mux); err != nil {
 		return nil, err
 	}
 
+	approvalCallbackURL, _ := url.Parse(config.Host.String())
+	approvalCallbackURL.Path = proxy.ApprovalCallbackPath
+	for _, proxyConfig := range config.Proxy {
+		if proxyConfig.Approval != nil && proxyConfig.Approval.Webhook != nil {
+			mux.Handle("POST "+proxy.ApprovalCallbackPath+"{id}", approvals.CallbackHandler())
+			break
+		}
+	}
+
 	for _, proxyConfig := range config.Proxy {
 		if proxyConfig.Http != nil && proxyConfig.Http.Url != nil {
-			handler := proxy.NewProxyHandler(&proxyConfig, oauthManager.UpdateWWWAuthenticateHeader)
+			handler, err := proxy.NewProxyHandler(
+				&proxyConfig,
+				sessions,
+				toolPins,
+				approvals,
+				approvalCallbackURL,
+				oauthManager.UpdateWWWAuthenticateHeader,
+			)
+			if err != nil {
+				return nil, fmt.Errorf("proxy %v: %w", proxyConfig.Path, err)
+			}
 			handler = htmlHandler.Handler(handler)
 
 			if proxyConfig.Authentication.Enabled {
-				handler = oauthManager.Handler(handler)
+				handler = oauthManager.Handler(&proxyConfig, handler)
 			}
 
 			mux.Handle(proxyConfig.Path, handler)
 		}
 	}
 
-	return mux, nil
+	return &hostRouter{Handler: mux, config: config, oauthManager: oauthManager}, nil
+}
+
+func (r *router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.hostRouterFor(req.Host).ServeHTTP(w, req)
+}
+
+// hostRouterFor returns the hostRouter whose host matches the given host header. A host with a port is matched
+// exactly first, then by its host name only.
+func (r *router) hostRouterFor(host string) *hostRouter {
+	for _, hr := range r.hosts[1:] {
+		if strings.EqualFold(hr.config.Host.Host, host) {
+			return hr
+		}
+	}
+
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		for _, hr := range r.hosts[1:] {
+			if strings.EqualFold((*url.URL)(hr.config.Host).Hostname(), hostname) {
+				return hr
+			}
+		}
+	}
+
+	return r.hosts[0]
 }
 
-func WatchConfigChanges(path string, callback func(*config.Config)) error {
+func (r *router) oauthManagers() []*oauth.Manager {
+	result := make([]*oauth.Manager, len(r.hosts))
+	for i, hr := range r.hosts {
+		result[i] = hr.oauthManager
+	}
+	return result
+}
+
+// WatchConfigChanges calls callback with the new configuration whenever the configuration file or one of the files
+// referenced by it changes.
+func WatchConfigChanges(path string, files []string, callback func(*config.Config)) error {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return err
@@ -145,15 +271,57 @@ func WatchConfigChanges(path string, callback func(*config.Config)) error {
 
 	// We watch the parent directory of the config file, rather than just the file itself, because Kubernetes uses
 	// symlinks when mounting ConfigMaps/Secrets and just watching the file doesn't work well in those cases.
-	fileDir := filepath.Dir(path)
-	fileName := filepath.Base(path)
+	watchedFiles := map[string]struct{}{}
+	watchFile := func(path string) error {
+		fileDir, err := filepath.Abs(filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+
+		if _, ok := watchedFiles[filepath.Join(fileDir, filepath.Base(path))]; ok {
+			return nil
+		}
 
-	if err := watcher.Add(fileDir); err != nil {
-		return fmt.Errorf("failed to watch directory %s: %w", fileDir, err)
+		if !slices.Contains(watcher.WatchList(), fileDir) {
+			if err := watcher.Add(fileDir); err != nil {
+				return fmt.Errorf("failed to watch directory %s: %w", fileDir, err)
+			}
+		}
+
+		watchedFiles[filepath.Join(fileDir, filepath.Base(path))] = struct{}{}
+		return nil
 	}
 
+	for _, p := range append([]string{path}, files...) {
+		if err := watchFile(p); err != nil {
+			return err
+		}
+	}
+
+	// Changes are usually reported as multiple events in quick succession, e.g. when an editor writes a file or when
+	// Kubernetes updates a mounted ConfigMap, so we wait until no more events have been received for a short time.
+	debounce := time.NewTimer(configReloadDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
 	for {
 		select {
+		case <-debounce.C:
+			log.Root().Info("starting config reload", "path", path)
+
+			if cfg, err := config.ParseFile(path); err != nil {
+				configReloadsTotal.Add(1)
+				configReloadFailuresTotal.Add(1)
+				log.Root().Error(err, "config reload error")
+			} else {
+				for _, f := range cfg.Files() {
+					if err := watchFile(f); err != nil {
+						log.Root().Error(err, "failed to watch referenced file", "path", f)
+					}
+				}
+
+				callback(cfg)
+			}
 		case err, ok := <-watcher.Errors:
 			if !ok {
 				return nil
@@ -164,24 +332,23 @@ func WatchConfigChanges(path string, callback func(*config.Config)) error {
 				return nil
 			}
 
-			// Check if the event is for our config file
-			if filepath.Base(event.Name) == fileName {
-				log.Root().Info("starting config reload", "op", event.Op, "path", event.Name)
+			eventName, _ := filepath.Abs(event.Name)
+			_, isWatchedFile := watchedFiles[eventName]
+			// Kubernetes updates mounted ConfigMaps and Secrets by atomically replacing the ..data symlink.
+			isKubernetesUpdate := filepath.Base(eventName) == "..data"
 
-				if cfg, err := config.ParseFile(path); err != nil {
-					log.Root().Error(err, "config reload error", "event", event)
-				} else {
-					callback(cfg)
-				}
+			if isWatchedFile || isKubernetesUpdate {
+				log.Root().V(1).Info("config change detected", "op", event.Op, "path", event.Name)
+				debounce.Reset(configReloadDebounce)
 			}
 		}
 	}
 }
 
 type delegateHandler struct {
-	delegate http.Handler
+	delegate atomic.Pointer[router]
 }
 
 func (h *delegateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	h.delegate.ServeHTTP(w, r)
+	h.delegate.Load().ServeHTTP(w, r)
 }
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hyprmcp/mcp-gateway/proxy"
+	"github.com/spf13/cobra"
+)
+
+func NewToolsCommand() *cobra.Command {
+	var opts AdminClientOptions
+	cmd := &cobra.Command{
+		Use:   "tools",
+		Short: "Manage pinned tool definitions of a running gateway",
+	}
+	BindAdminClientOptions(cmd, &opts)
+	cmd.AddCommand(newToolsPinsCommand(&opts), newToolsChangesCommand(&opts), newToolsApproveCommand(&opts))
+	return cmd
+}
+
+func newToolsPinsCommand(opts *AdminClientOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:          "pins",
+		Short:        "List all pinned tool definitions",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var pins []proxy.ToolPin
+			if err := adminRequest(cmd.Context(), *opts, http.MethodGet, "/tools/pins", nil, &pins); err != nil {
+				return err
+			}
+
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(tw, "UPSTREAM\tTOOL\tDIGEST\tPINNED")
+			for _, p := range pins {
+				_, _ = fmt.Fprintf(tw, "%v\t%v\t%v\t%v\n", p.Upstream, p.Tool, p.Digest, p.PinnedAt.Format(time.RFC3339))
+			}
+			return tw.Flush()
+		},
+	}
+}
+
+type ToolsChangesOptions struct {
+	ShowDefinitions bool
+}
+
+func newToolsChangesCommand(adminOpts *AdminClientOptions) *cobra.Command {
+	var opts ToolsChangesOptions
+	cmd := &cobra.Command{
+		Use:          "changes",
+		Short:        "List changed tool definitions that are waiting for an approval",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var changes []proxy.ToolChange
+			if err := adminRequest(cmd.Context(), *adminOpts, http.MethodGet, "/tools/changes", nil, &changes); err != nil {
+				return err
+			}
+			return printToolChanges(cmd.OutOrStdout(), changes, opts.ShowDefinitions)
+		},
+	}
+	cmd.Flags().BoolVar(&opts.ShowDefinitions, "show-definitions", false, "Print the changed tool definitions")
+	return cmd
+}
+
+func newToolsApproveCommand(opts *AdminClientOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:          "approve UPSTREAM TOOL DIGEST",
+		Short:        "Approve a changed tool definition",
+		Long:         "Approve a changed tool definition. The digest must match the pending change as listed by \"tools changes\".",
+		Args:         cobra.ExactArgs(3),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query := url.Values{"upstream": {args[0]}, "tool": {args[1]}, "digest": {args[2]}}
+			if err := adminRequest(cmd.Context(), *opts, http.MethodPost, "/tools/changes/approve", query, nil); err != nil {
+				return err
+			}
+
+			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "tool %v approved\n", args[1])
+			return nil
+		},
+	}
+}
+
+func printToolChanges(w io.Writer, changes []proxy.ToolChange, showDefinitions bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "UPSTREAM\tTOOL\tPINNED DIGEST\tDIGEST\tDETECTED")
+	for _, c := range changes {
+		pinned := string(c.PinnedDigest)
+		if pinned == "" {
+			pinned = "<new>"
+		}
+		_, _ = fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\n", c.Upstream, c.Tool, pinned, c.Digest, c.DetectedAt.Format(time.RFC3339))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if showDefinitions {
+		for _, c := range changes {
+			_, _ = fmt.Fprintf(w, "\n%v %v:\n%s\n", c.Upstream, c.Tool, c.Definition)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hyprmcp/mcp-gateway/config"
+	"github.com/hyprmcp/mcp-gateway/oauth"
+	"github.com/spf13/cobra"
+)
+
+type ValidateOptions struct {
+	Config string
+}
+
+func NewValidateCommand() *cobra.Command {
+	var opts ValidateOptions
+	cmd := &cobra.Command{
+		Use:          "validate",
+		Short:        "Validate a configuration file",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runValidate(cmd, opts)
+		},
+	}
+	cmd.Flags().StringVarP(&opts.Config, "config", "c", "config.yaml", "Path to the configuration file")
+	return cmd
+}
+
+func runValidate(cmd *cobra.Command, opts ValidateOptions) error {
+	cfg, err := config.ParseFile(opts.Config)
+	if cfg == nil {
+		return err
+	}
+
+	var issues []config.Issue
+	if err != nil {
+		issues = append(issues, config.Issue{Severity: config.SeverityError, Message: err.Error()})
+	}
+
+	issues = append(issues, cfg.Check()...)
+	issues = append(issues, checkRoutes(cfg)...)
+	issues = append(issues, checkRules(cfg)...)
+
+	errorCount := 0
+	for _, issue := range issues {
+		if issue.Severity == config.SeverityError {
+			errorCount++
+		}
+		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%v: %v\n", opts.Config, issue)
+	}
+
+	if errorCount > 0 {
+		return fmt.Errorf("configuration is invalid: %v error(s) found", errorCount)
+	}
+
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%v: configuration is valid\n", opts.Config)
+	return nil
+}
+
+// checkRoutes registers all routes of each host of the configuration on a new http.ServeMux to find conflicting
+// patterns and reports proxy paths that overlap with each other or with the routes of the gateway.
+func checkRoutes(cfg *config.Config) (issues []config.Issue) {
+	for i, hostConfig := range cfg.VirtualHostConfigs() {
+		var pathPrefix string
+		if i > 0 {
+			pathPrefix = "virtualHosts." + strconv.Itoa(i-1) + "."
+		}
+		issues = append(issues, checkHostRoutes(cfg, hostConfig, pathPrefix)...)
+	}
+	return issues
+}
+
+func checkHostRoutes(cfg, hostConfig *config.Config, pathPrefix string) (issues []config.Issue) {
+	type route struct {
+		pattern string
+		path    string
+	}
+
+	routes := []route{
+		{pattern: oauth.ProtectedResourcePath},
+		{pattern: strings.TrimSuffix(oauth.ProtectedResourcePath, "/")},
+	}
+	addAuthorizationRoutes := func(auth *config.Authorization, prefix string) {
+		if auth.ServerMetadataProxyEnabled {
+			routes = append(routes, route{pattern: oauth.AuthorizationServerMetadataPath + prefix})
+		}
+		if dcr := auth.GetDynamicClientRegistration(); dcr.Enabled {
+			routes = append(routes, route{pattern: oauth.DynamicClientRegistrationPath + prefix})
+			routes = append(routes, route{pattern: oauth.ClientConfigurationPath + strings.TrimSuffix(prefix, "/") + "/{clientID}"})
+			if dcr.GetBackend() == config.RegistrationBackendStatic || dcr.GetClientIDMetadataDocument().Enabled {
+				routes = append(routes, route{pattern: oauth.TokenPath + prefix})
+			}
+		}
+		if auth.AuthorizationProxyEnabled {
+			routes = append(routes, route{pattern: oauth.AuthorizationPath + prefix})
+		}
+	}
+
+	addAuthorizationRoutes(&hostConfig.Authorization, "")
+	for _, proxyConfig := range hostConfig.Proxy {
+		if proxyConfig.Authorization != nil || proxyConfig.DexGRPCClient != nil {
+			auth, _ := hostConfig.AuthorizationFor(&proxyConfig)
+			addAuthorizationRoutes(auth, proxyConfig.Path)
+		}
+	}
+	// duplicate proxy paths are already reported by config.Check
+	seenPaths := map[string]struct{}{}
+	for i, proxyConfig := range hostConfig.Proxy {
+		if _, ok := seenPaths[proxyConfig.Path]; ok {
+			continue
+		}
+		seenPaths[proxyConfig.Path] = struct{}{}
+		if proxyConfig.Http != nil && proxyConfig.Http.Url != nil && strings.HasPrefix(proxyConfig.Path, "/") {
+			routes = append(routes, route{pattern: proxyConfig.Path, path: pathPrefix + "proxy." + strconv.Itoa(i) + ".path"})
+		}
+	}
+
+	mux := http.NewServeMux()
+	for i, r := range routes {
+		if err := registerPattern(mux, r.pattern); err != nil {
+			issues = append(issues, cfg.NewIssue(config.SeverityError, r.path, "%v", err))
+			continue
+		}
+
+		if r.path == "" {
+			continue
+		}
+
+		for _, other := range routes[:i] {
+			if strings.HasSuffix(other.pattern, "/") && strings.HasPrefix(r.pattern, other.pattern) {
+				issues = append(issues, cfg.NewIssue(config.SeverityWarning, r.path,
+					"path %q overlaps with %q", r.pattern, other.pattern))
+			} else if strings.HasSuffix(r.pattern, "/") && strings.HasPrefix(other.pattern, r.pattern) {
+				issues = append(issues, cfg.NewIssue(config.SeverityWarning, r.path,
+					"path %q overlaps with %q", r.pattern, other.pattern))
+			}
+		}
+	}
+
+	return issues
+}
+
+func registerPattern(mux *http.ServeMux, pattern string) (err error) {
+	// http.ServeMux panics if a pattern is invalid or conflicts with an existing pattern
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("pattern %q is invalid or conflicts with another route", pattern)
+		}
+	}()
+
+	mux.Handle(pattern, http.NotFoundHandler())
+	return nil
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Issue is a problem in the configuration that was found by Check.
+type Issue struct {
+	Severity Severity
+	Path     string
+	Line     int
+	Column   int
+	Message  string
+}
+
+func (i Issue) String() string {
+	var sb strings.Builder
+	sb.WriteString(string(i.Severity))
+	if i.Line > 0 {
+		fmt.Fprintf(&sb, " at line %v, column %v", i.Line, i.Column)
+	}
+	if i.Path != "" {
+		fmt.Fprintf(&sb, " (%v)", i.Path)
+	}
+	sb.WriteString(": ")
+	sb.WriteString(i.Message)
+	return sb.String()
+}
+
+// NewIssue creates an Issue for the value at the given dot-separated path, e.g. "proxy.0.http.url". The position of
+// the value in the configuration file is included if it is known.
+func (c *Config) NewIssue(severity Severity, path string, format string, args ...any) Issue {
+	issue := Issue{Severity: severity, Path: path, Message: fmt.Sprintf(format, args...)}
+	if c.node != nil {
+		var segments []string
+		if path != "" {
+			segments = strings.Split(path, ".")
+		}
+		if n := lookupNode(c.node, segments); n != nil {
+			issue.Line = n.Line
+			issue.Column = n.Column
+		}
+	}
+	return issue
+}
+
+// Check performs additional checks on the configuration that go beyond Validate, e.g. it makes sure that all URLs
+// have a supported scheme and that all referenced files are readable. The result also includes all Warnings.
+func (c *Config) Check() []Issue {
+	issues := append([]Issue{}, c.warnings...)
+
+	checkURL := func(path string, u *url.URL) {
+		if u == nil {
+			return
+		} else if u.Scheme != "http" && u.Scheme != "https" {
+			issues = append(issues, c.NewIssue(SeverityError, path, "URL %q must use the http or https scheme", u))
+		} else if u.Host == "" {
+			issues = append(issues, c.NewIssue(SeverityError, path, "URL %q must have a host", u))
+		}
+	}
+
+	checkURL("host", (*url.URL)(c.Host))
+
+	checkAuthorization := func(prefix string, auth *Authorization, dex *DexGRPCClient) {
+		if auth != nil && auth.Server != "" {
+			if u, err := url.Parse(auth.Server); err != nil {
+				issues = append(issues, c.NewIssue(SeverityError, prefix+"authorization.server", "%v", err))
+			} else {
+				checkURL(prefix+"authorization.server", u)
+			}
+		}
+
+		if auth != nil && auth.DynamicClientRegistration != nil && auth.DynamicClientRegistration.Keycloak != nil {
+			checkURL(prefix+"authorization.dynamicClientRegistration.keycloak.url",
+				(*url.URL)(auth.DynamicClientRegistration.Keycloak.URL))
+		}
+
+		if dex != nil {
+			for _, f := range []struct{ name, fileName string }{
+				{"tlsCert", dex.TLSCert},
+				{"tlsKey", dex.TLSKey},
+				{"tlsClientCA", dex.TLSClientCA},
+			} {
+				if f.fileName == "" {
+					continue
+				} else if file, err := os.Open(f.fileName); err != nil {
+					issues = append(issues, c.NewIssue(SeverityError, prefix+"dexGRPCClient."+f.name, "file is not readable: %v", err))
+				} else {
+					_ = file.Close()
+				}
+			}
+		}
+	}
+
+	checkProxies := func(pathPrefix string, proxies []Proxy) {
+		proxyPaths := map[string]int{}
+		for i, proxy := range proxies {
+			prefix := pathPrefix + "proxy." + strconv.Itoa(i)
+
+			if proxy.Path == "" {
+				issues = append(issues, c.NewIssue(SeverityError, prefix, "path is required"))
+			} else if !strings.HasPrefix(proxy.Path, "/") {
+				issues = append(issues, c.NewIssue(SeverityError, prefix+".path", "path %q must start with /", proxy.Path))
+			} else if other, ok := proxyPaths[proxy.Path]; ok {
+				issues = append(issues, c.NewIssue(SeverityError, prefix+".path", "path %q is already used by proxy %v", proxy.Path, other))
+			} else {
+				proxyPaths[proxy.Path] = i
+			}
+
+			if proxy.Http == nil || proxy.Http.Url == nil {
+				issues = append(issues, c.NewIssue(SeverityWarning, prefix, "proxy has no http.url and will be ignored"))
+			} else {
+				checkURL(prefix+".http.url", (*url.URL)(proxy.Http.Url))
+			}
+
+			if proxy.Webhook != nil {
+				checkURL(prefix+".webhook.url", (*url.URL)(&proxy.Webhook.Url))
+			} else if proxy.Validation.ToolResults == ToolResultValidationAnnotate && !proxy.Sinks.Log && proxy.Sinks.File == "" {
+				issues = append(issues, c.NewIssue(SeverityWarning, prefix+".validation.toolResults",
+					"validation errors can not be annotated because the proxy has no webhook, log or file sink"))
+			}
+
+			if proxy.Inspection != nil && proxy.Inspection.Scanner != nil {
+				checkURL(prefix+".inspection.scanner.url", (*url.URL)(&proxy.Inspection.Scanner.URL))
+			}
+
+			if proxy.Approval != nil && proxy.Approval.Webhook != nil {
+				checkURL(prefix+".approval.webhook.url", (*url.URL)(&proxy.Approval.Webhook.Url))
+			}
+
+			checkAuthorization(prefix+".", proxy.Authorization, proxy.DexGRPCClient)
+		}
+	}
+
+	checkAuthorization("", &c.Authorization, c.DexGRPCClient)
+	checkProxies("", c.Proxy)
+
+	for i, vh := range c.VirtualHosts {
+		prefix := "virtualHosts." + strconv.Itoa(i) + "."
+		if vh.Host == nil {
+			issues = append(issues, c.NewIssue(SeverityError, "virtualHosts."+strconv.Itoa(i), "host is required"))
+		} else {
+			checkURL(prefix+"host", (*url.URL)(vh.Host))
+		}
+		checkAuthorization(prefix, vh.Authorization, vh.DexGRPCClient)
+		checkProxies(prefix, vh.Proxy)
+	}
+
+	return issues
+}
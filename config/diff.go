@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+)
+
+// Change describes a single value that differs between two configurations. Secrets are redacted.
+type Change struct {
+	Path string `json:"path"`
+	Old  any    `json:"old,omitempty"`
+	New  any    `json:"new,omitempty"`
+}
+
+func (c Change) String() string {
+	switch {
+	case c.Old == nil:
+		return fmt.Sprintf("%v: added %v", c.Path, c.New)
+	case c.New == nil:
+		return fmt.Sprintf("%v: removed %v", c.Path, c.Old)
+	default:
+		return fmt.Sprintf("%v: %v -> %v", c.Path, c.Old, c.New)
+	}
+}
+
+// Diff returns all values that differ between the configurations a and b, ordered by path.
+func Diff(a, b *Config) []Change {
+	valuesA, valuesB := map[string]any{}, map[string]any{}
+	flatten(a.MarshalLog(), "", valuesA)
+	flatten(b.MarshalLog(), "", valuesB)
+
+	var changes []Change
+	for _, path := range slices.Sorted(maps.Keys(valuesA)) {
+		if newValue, ok := valuesB[path]; !ok {
+			changes = append(changes, Change{Path: path, Old: valuesA[path]})
+		} else if newValue != valuesA[path] {
+			changes = append(changes, Change{Path: path, Old: valuesA[path], New: newValue})
+		}
+	}
+	for _, path := range slices.Sorted(maps.Keys(valuesB)) {
+		if _, ok := valuesA[path]; !ok {
+			changes = append(changes, Change{Path: path, New: valuesB[path]})
+		}
+	}
+
+	slices.SortStableFunc(changes, func(x, y Change) int {
+		if x.Path < y.Path {
+			return -1
+		} else if x.Path > y.Path {
+			return 1
+		}
+		return 0
+	})
+	return changes
+}
+
+func flatten(value any, path string, result map[string]any) {
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			flatten(child, join(key), result)
+		}
+	case []any:
+		for i, child := range v {
+			flatten(child, join(strconv.Itoa(i)), result)
+		}
+	case nil:
+	default:
+		result[path] = v
+	}
+}
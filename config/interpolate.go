@@ -0,0 +1,168 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const filePrefix = "file:"
+
+// interpolator expands references in scalar values of a YAML document. The following forms are supported:
+//
+//   - ${NAME} is replaced with the value of the environment variable NAME. It is an error if NAME is not set.
+//   - ${NAME:-default} is replaced with the value of NAME or with default, if NAME is not set or empty.
+//   - ${file:/path/to/file} is replaced with the content of the file, excluding trailing newlines. This is meant to be
+//     used for secrets that are mounted into the container, e.g. from a Kubernetes Secret.
+//   - $$ is replaced with a literal $.
+//
+// All values that contain a reference that was resolved from the environment or a file are considered secret and are
+// redacted when the configuration is printed or logged.
+type interpolator struct {
+	secretPaths [][]string
+	files       []string
+}
+
+func (ip *interpolator) interpolateNode(node *yaml.Node, path []string) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, n := range node.Content {
+			if err := ip.interpolateNode(n, path); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if err := ip.interpolateNode(node.Content[i+1], append(path, node.Content[i].Value)); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for i, n := range node.Content {
+			if err := ip.interpolateNode(n, append(path, strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "$") {
+			return nil
+		}
+
+		if value, secret, err := ip.expand(node.Value); err != nil {
+			return fmt.Errorf("line %v: %w", node.Line, err)
+		} else {
+			node.Value = value
+			if node.Style == 0 {
+				// plain scalars are resolved again, so that e.g. booleans can be set from environment variables
+				node.Tag = ""
+			}
+			if secret {
+				ip.secretPaths = append(ip.secretPaths, append([]string{}, path...))
+			}
+		}
+	}
+
+	return nil
+}
+
+func (ip *interpolator) expand(s string) (string, bool, error) {
+	var result strings.Builder
+	var secret bool
+
+	for len(s) > 0 {
+		idx := strings.IndexByte(s, '$')
+		if idx < 0 || idx == len(s)-1 {
+			result.WriteString(s)
+			break
+		}
+
+		result.WriteString(s[:idx])
+		s = s[idx:]
+
+		switch s[1] {
+		case '$':
+			result.WriteByte('$')
+			s = s[2:]
+		case '{':
+			end := strings.IndexByte(s, '}')
+			if end < 0 {
+				return "", false, fmt.Errorf("unterminated reference in %q", s)
+			}
+
+			if value, isSecret, err := ip.resolve(s[2:end]); err != nil {
+				return "", false, err
+			} else {
+				result.WriteString(value)
+				secret = secret || isSecret
+			}
+
+			s = s[end+1:]
+		default:
+			result.WriteByte('$')
+			s = s[1:]
+		}
+	}
+
+	return result.String(), secret, nil
+}
+
+func (ip *interpolator) resolve(ref string) (string, bool, error) {
+	if fileName, ok := strings.CutPrefix(ref, filePrefix); ok {
+		if data, err := os.ReadFile(fileName); err != nil {
+			return "", false, fmt.Errorf("failed to read referenced file: %w", err)
+		} else {
+			ip.files = append(ip.files, fileName)
+			return strings.TrimRight(string(data), "\r\n"), true, nil
+		}
+	}
+
+	name, defaultValue, hasDefault := strings.Cut(ref, ":-")
+	if name == "" {
+		return "", false, fmt.Errorf("invalid reference ${%v}", ref)
+	} else if value, ok := os.LookupEnv(name); ok && (value != "" || !hasDefault) {
+		return value, true, nil
+	} else if hasDefault {
+		return defaultValue, false, nil
+	} else {
+		return "", false, fmt.Errorf("environment variable %v is not set", name)
+	}
+}
+
+// redactNode replaces all values in node that were marked as secret during interpolation.
+func (c *Config) redactNode(node *yaml.Node) {
+	for _, path := range c.secretPaths {
+		if n := lookupNode(node, path); n != nil && n.Kind == yaml.ScalarNode {
+			n.Value = Redacted
+			n.Tag = "!!str"
+			n.Style = 0
+		}
+	}
+}
+
+func lookupNode(node *yaml.Node, path []string) *yaml.Node {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+
+	for _, key := range path {
+		switch node.Kind {
+		case yaml.MappingNode:
+			if node = mappingValue(node, key); node == nil {
+				return nil
+			}
+		case yaml.SequenceNode:
+			if idx, err := strconv.Atoi(key); err != nil || idx >= len(node.Content) {
+				return nil
+			} else {
+				node = node.Content[idx]
+			}
+		default:
+			return nil
+		}
+	}
+
+	return node
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// migration rewrites a deprecated part of the configuration document to the current shape. It returns a description
+// of each change that was made to the document.
+type migration func(root *yaml.Node) []string
+
+var migrations = []migration{
+	migrateDynamicClientRegistrationEnabled,
+}
+
+// Migrate reads a configuration file and writes it to w with all deprecated fields replaced by their current
+// counterparts. Environment variable and file references are preserved. It returns a description of each change.
+func Migrate(r io.Reader, w io.Writer) ([]string, error) {
+	var node yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&node); err != nil {
+		return nil, fmt.Errorf("failed to decode config: %w", err)
+	}
+
+	var changes []string
+	if len(node.Content) > 0 && node.Content[0].Kind == yaml.MappingNode {
+		for _, m := range migrations {
+			changes = append(changes, m(node.Content[0])...)
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return nil, err
+	} else if err := enc.Close(); err != nil {
+		return nil, err
+	} else if _, err := io.Copy(w, &buf); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+func migrateDynamicClientRegistrationEnabled(root *yaml.Node) []string {
+	var changes []string
+
+	// the authorization field can be set at the top level, for each virtual host and for each proxy
+	migrateHost := func(host *yaml.Node, prefix string) {
+		if change, ok := migrateAuthorizationDynamicClientRegistrationEnabled(host, prefix+"authorization"); ok {
+			changes = append(changes, change)
+		}
+
+		forEachMapping(mappingValue(host, "proxy"), func(i int, proxy *yaml.Node) {
+			path := fmt.Sprintf("%vproxy.%v.authorization", prefix, i)
+			if change, ok := migrateAuthorizationDynamicClientRegistrationEnabled(proxy, path); ok {
+				changes = append(changes, change)
+			}
+		})
+	}
+
+	migrateHost(root, "")
+	forEachMapping(mappingValue(root, "virtualHosts"), func(i int, vh *yaml.Node) {
+		migrateHost(vh, fmt.Sprintf("virtualHosts.%v.", i))
+	})
+
+	return changes
+}
+
+func forEachMapping(seq *yaml.Node, fn func(int, *yaml.Node)) {
+	if seq != nil && seq.Kind == yaml.SequenceNode {
+		for i, n := range seq.Content {
+			if n.Kind == yaml.MappingNode {
+				fn(i, n)
+			}
+		}
+	}
+}
+
+func migrateAuthorizationDynamicClientRegistrationEnabled(parent *yaml.Node, path string) (string, bool) {
+	authorization := mappingValue(parent, "authorization")
+	if authorization == nil || authorization.Kind != yaml.MappingNode {
+		return "", false
+	}
+
+	idx := mappingIndex(authorization, "dynamicClientRegistrationEnabled")
+	if idx < 0 {
+		return "", false
+	}
+
+	deprecated := authorization.Content[idx+1]
+	authorization.Content = append(authorization.Content[:idx], authorization.Content[idx+2:]...)
+
+	var enabled bool
+	if mappingValue(authorization, "dynamicClientRegistration") != nil {
+		return fmt.Sprintf("removed %[1]v.dynamicClientRegistrationEnabled, because %[1]v.dynamicClientRegistration is set", path), true
+	} else if err := deprecated.Decode(&enabled); err != nil || !enabled {
+		return fmt.Sprintf("removed %v.dynamicClientRegistrationEnabled", path), true
+	}
+
+	var replacement yaml.Node
+	_ = replacement.Encode(DynamicClientRegistration{Enabled: true, PublicClient: true})
+	authorization.Content = slices.Insert(authorization.Content, idx,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "dynamicClientRegistration"},
+		&replacement,
+	)
+
+	return fmt.Sprintf("replaced %[1]v.dynamicClientRegistrationEnabled with %[1]v.dynamicClientRegistration", path), true
+}
+
+func mappingIndex(node *yaml.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if idx := mappingIndex(node, key); idx >= 0 {
+		return node.Content[idx+1]
+	}
+	return nil
+}
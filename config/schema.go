@@ -0,0 +1,76 @@
+package config
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// JSONSchema returns a JSON Schema of the configuration file, which can be used for editor autocompletion and for
+// validating configuration files in CI.
+func JSONSchema() (*jsonschema.Schema, error) {
+	schema, err := jsonschema.For[Config](&jsonschema.ForOptions{
+		TypeSchemas: map[reflect.Type]*jsonschema.Schema{
+			reflect.TypeFor[URL]():           {Type: "string", Format: "uri"},
+			reflect.TypeFor[time.Duration](): {Type: "string", Pattern: `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Most fields are optional, even if they are not marked as omitempty.
+	walkSchema(schema, func(s *jsonschema.Schema) { s.Required = nil })
+
+	schema.Schema = "https://json-schema.org/draft/2020-12/schema"
+	schema.Title = "MCP Gateway configuration"
+	schema.Required = []string{"host", "authorization"}
+	schema.Properties["admin"].Required = []string{"token"}
+	virtualHostSchema := schema.Properties["virtualHosts"].Items
+	virtualHostSchema.Required = []string{"host"}
+
+	for _, s := range []*jsonschema.Schema{schema, virtualHostSchema} {
+		proxySchema := s.Properties["proxy"].Items
+		proxySchema.Required = []string{"path"}
+		proxySchema.Properties["http"].Required = []string{"url"}
+		proxySchema.Properties["webhook"].Required = []string{"url"}
+		proxySchema.Properties["approval"].Required = []string{"tools"}
+		proxySchema.Properties["approval"].Properties["webhook"].Required = []string{"url"}
+
+		for _, s := range []*jsonschema.Schema{s, proxySchema} {
+			s.Properties["dexGRPCClient"].Required = []string{"addr"}
+			s.Properties["authorization"].Required = []string{"server"}
+			s.Properties["authorization"].Properties["dynamicClientRegistrationEnabled"].Deprecated = true
+			dcrSchema := s.Properties["authorization"].Properties["dynamicClientRegistration"]
+			dcrSchema.Properties["backend"].Enum = []any{
+				RegistrationBackendDex, RegistrationBackendKeycloak, RegistrationBackendStatic,
+			}
+			dcrSchema.Properties["keycloak"].Required = []string{"url", "realm", "clientId", "clientSecret"}
+			dcrSchema.Properties["static"].Required = []string{"clientId"}
+			dcrSchema.Properties["policy"].Properties["requiredFields"].Items.Enum = func() (result []any) {
+				for _, f := range RegistrationPolicyFields {
+					result = append(result, f)
+				}
+				return result
+			}()
+		}
+	}
+
+	return schema, nil
+}
+
+func walkSchema(s *jsonschema.Schema, fn func(*jsonschema.Schema)) {
+	if s == nil {
+		return
+	}
+
+	fn(s)
+
+	for _, p := range s.Properties {
+		walkSchema(p, fn)
+	}
+
+	walkSchema(s.Items, fn)
+	walkSchema(s.AdditionalProperties, fn)
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"go.uber.org/multierr"
+	"gopkg.in/yaml.v3"
+)
+
+var yamlUnmarshalerType = reflect.TypeFor[yaml.Unmarshaler]()
+
+// fieldChecker walks a YAML document alongside the Go type it is decoded into. It reports keys that don't correspond
+// to a field of the target type as errors and collects warnings for fields that have a "deprecated" struct tag.
+type fieldChecker struct {
+	warnings []Issue
+}
+
+func (fc *fieldChecker) check(node *yaml.Node, t reflect.Type, path []string) (err error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if reflect.PointerTo(t).Implements(yamlUnmarshalerType) {
+		return nil
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, n := range node.Content {
+			multierr.AppendInto(&err, fc.check(n, t, path))
+		}
+	case yaml.AliasNode:
+		if node.Alias != nil {
+			multierr.AppendInto(&err, fc.check(node.Alias, t, path))
+		}
+	case yaml.SequenceNode:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i, n := range node.Content {
+				multierr.AppendInto(&err, fc.check(n, t.Elem(), append(path, strconv.Itoa(i))))
+			}
+		}
+	case yaml.MappingNode:
+		switch t.Kind() {
+		case reflect.Map:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				multierr.AppendInto(&err, fc.check(node.Content[i+1], t.Elem(), append(path, node.Content[i].Value)))
+			}
+		case reflect.Struct:
+			fields := yamlFields(t)
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key, value := node.Content[i], node.Content[i+1]
+				if key.Value == "<<" {
+					continue
+				}
+
+				if field, ok := fields[key.Value]; !ok {
+					multierr.AppendInto(&err, fmt.Errorf("line %v: field %v not found in type %v", key.Line, key.Value, t))
+				} else {
+					fieldPath := append(path, key.Value)
+					if msg, ok := field.Tag.Lookup("deprecated"); ok {
+						fc.warnings = append(fc.warnings, Issue{
+							Severity: SeverityWarning,
+							Path:     strings.Join(fieldPath, "."),
+							Line:     key.Line,
+							Column:   key.Column,
+							Message:  fmt.Sprintf("field %v is deprecated: %v", key.Value, msg),
+						})
+					}
+					multierr.AppendInto(&err, fc.check(value, field.Type, fieldPath))
+				}
+			}
+		}
+	}
+
+	return err
+}
+
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, flags, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		} else if strings.Contains(flags, "inline") {
+			for k, v := range yamlFields(field.Type) {
+				fields[k] = v
+			}
+			continue
+		} else if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		fields[name] = field
+	}
+	return fields
+}
@@ -1,7 +1,7 @@
 package htmlresponse
 
 import (
-	_ "embed"
+	"embed"
 	"html/template"
 	"net/http"
 	"net/url"
@@ -12,9 +12,9 @@ import (
 )
 
 var (
-	//go:embed template.html
-	ts  string
-	tpl *template.Template
+	//go:embed *.html
+	templates embed.FS
+	tpl       *template.Template
 )
 
 const (
@@ -22,7 +22,7 @@ const (
 )
 
 func init() {
-	if t, err := template.New("").Parse(ts); err != nil {
+	if t, err := template.ParseFS(templates, "*.html"); err != nil {
 		panic(err)
 	} else {
 		tpl = t
@@ -73,5 +73,42 @@ func (h *handler) handleHtml(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotAcceptable)
 	}
 
-	_ = tpl.Execute(w, data)
+	_ = tpl.ExecuteTemplate(w, "template.html", data)
+}
+
+// WriteError renders an error page for requests that were opened in a browser and can not be handled.
+func WriteError(w http.ResponseWriter, status int, title, description, hint string) {
+	data := struct {
+		Title       string
+		Description string
+		Hint        string
+	}{Title: title, Description: description, Hint: hint}
+
+	w.Header().Set("Content-Type", ContentTypeTextHTML)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = tpl.ExecuteTemplate(w, "error.html", data)
+}
+
+// ConsentData is shown on the consent page of the authorization proxy.
+type ConsentData struct {
+	ClientID   string
+	ClientName string
+	LogoURI    string
+	// Registered is false if the client is not known to the gateway and its name could not be verified.
+	Registered bool
+	Server     string
+	Scopes     []string
+	// Action is the URL to which the decision is posted together with CSRFToken.
+	Action    string
+	CSRFToken string
+}
+
+// WriteConsent renders the consent page. It must not be embedded in frames of other sites.
+func WriteConsent(w http.ResponseWriter, data ConsentData) {
+	w.Header().Set("Content-Type", ContentTypeTextHTML)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	_ = tpl.ExecuteTemplate(w, "consent.html", data)
 }
@@ -0,0 +1,91 @@
+package inspect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/hyprmcp/mcp-gateway/config"
+)
+
+const (
+	defaultScannerTimeout = 5 * time.Second
+	// maxScannerResponseSize limits the response of a scanner, which may contain the redacted content.
+	maxScannerResponseSize = 10 << 20
+)
+
+// httpScanner sends the content to an external scanner via HTTP.
+type httpScanner struct {
+	url        string
+	client     *http.Client
+	failClosed bool
+}
+
+func newHTTPScanner(cfg *config.InspectionScanner) *httpScanner {
+	timeout := defaultScannerTimeout
+	if cfg.Timeout > 0 {
+		timeout = cfg.Timeout
+	}
+
+	return &httpScanner{url: cfg.URL.String(), client: &http.Client{Timeout: timeout}, failClosed: cfg.FailClosed}
+}
+
+func (s *httpScanner) Scan(ctx context.Context, req Request) (*Result, error) {
+	result, err := s.scan(ctx, req)
+	if err == nil {
+		return result, nil
+	}
+
+	err = fmt.Errorf("inspection scanner %v: %w", s.url, err)
+	if s.failClosed {
+		return &Result{
+			Action:   config.InspectionActionBlock,
+			Findings: []Finding{{Scanner: s.url, Message: "scanner failed"}},
+		}, err
+	} else {
+		return nil, err
+	}
+}
+
+func (s *httpScanner) scan(ctx context.Context, req Request) (*Result, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	var result Result
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("unexpected http status: %v", resp.Status)
+	} else if err := json.NewDecoder(io.LimitReader(resp.Body, maxScannerResponseSize)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	} else if result.Action.Severity() < 0 {
+		return nil, fmt.Errorf("unknown action %q", result.Action)
+	} else if result.Action == config.InspectionActionRedact && !json.Valid(result.Content) {
+		return nil, fmt.Errorf("redact action without valid content")
+	}
+
+	for i := range result.Findings {
+		if result.Findings[i].Scanner == "" {
+			result.Findings[i].Scanner = s.url
+		}
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,194 @@
+// Package inspect scans the content of tools/call messages, e.g. for prompt injections, before they are forwarded.
+package inspect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/hyprmcp/mcp-gateway/config"
+	"github.com/hyprmcp/mcp-gateway/webhook"
+)
+
+type Target string
+
+const (
+	TargetToolArguments Target = "toolArguments"
+	TargetToolResult    Target = "toolResult"
+)
+
+// Request is the content that is inspected by a Scanner.
+type Request struct {
+	Target Target `json:"target"`
+	Tool   string `json:"tool"`
+	// Content is the arguments object of a tools/call request or the result of a tools/call response.
+	Content json.RawMessage `json:"content"`
+}
+
+type Finding = webhook.InspectionFinding
+
+// Result is the result of a Scanner.
+type Result struct {
+	Action   config.InspectionAction `json:"action"`
+	Findings []Finding               `json:"findings,omitempty"`
+	// Content replaces the inspected content if the action is redact.
+	Content json.RawMessage `json:"content,omitempty"`
+}
+
+// Scanner inspects the content of a message. Implementations must be safe for concurrent use. Scanners for other
+// protocols, e.g. gRPC, can be added by implementing this interface and creating them in New.
+type Scanner interface {
+	Scan(ctx context.Context, req Request) (*Result, error)
+}
+
+// Inspector runs all configured scanners. A nil Inspector doesn't inspect anything.
+type Inspector struct {
+	scanners  []Scanner
+	arguments bool
+}
+
+// New creates an Inspector for the given configuration. It returns nil if cfg is nil.
+func New(cfg *config.Inspection) (*Inspector, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	i := &Inspector{arguments: cfg.Arguments}
+
+	if len(cfg.Rules) > 0 {
+		if s, err := newRegexScanner(cfg.Rules); err != nil {
+			return nil, err
+		} else {
+			i.scanners = append(i.scanners, s)
+		}
+	}
+
+	if cfg.Scanner != nil {
+		i.scanners = append(i.scanners, newHTTPScanner(cfg.Scanner))
+	}
+
+	return i, nil
+}
+
+// InspectsArguments returns true if tools/call arguments are inspected in addition to tool results.
+func (i *Inspector) InspectsArguments() bool {
+	return i != nil && i.arguments
+}
+
+// Inspect runs all scanners in order and returns the most severe action and the findings of all scanners. If a scanner
+// redacts the content, the following scanners inspect the redacted content and the result contains it. Errors of
+// scanners are returned in addition to the result, which already reflects whether the scanner fails closed.
+func (i *Inspector) Inspect(ctx context.Context, req Request) (Result, error) {
+	result := Result{Action: config.InspectionActionAllow}
+	var errs []error
+
+	for _, s := range i.scanners {
+		r, err := s.Scan(ctx, req)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if r == nil {
+			continue
+		}
+
+		result.Findings = append(result.Findings, r.Findings...)
+		if r.Action.Severity() > result.Action.Severity() {
+			result.Action = r.Action
+		}
+		if r.Action == config.InspectionActionRedact && r.Content != nil {
+			req.Content = r.Content
+			result.Content = r.Content
+		}
+	}
+
+	return result, errors.Join(errs...)
+}
+
+// regexScanner matches the rules against all strings in the content.
+type regexScanner struct {
+	rules []regexRule
+}
+
+type regexRule struct {
+	name   string
+	re     *regexp.Regexp
+	action config.InspectionAction
+}
+
+func newRegexScanner(rules []config.InspectionRule) (*regexScanner, error) {
+	s := &regexScanner{}
+	for _, rule := range rules {
+		if re, err := regexp.Compile(rule.Pattern); err != nil {
+			return nil, fmt.Errorf("inspection rule %v: %w", rule.Name, err)
+		} else {
+			action := rule.Action
+			if action == "" {
+				action = config.InspectionActionAnnotate
+			}
+			s.rules = append(s.rules, regexRule{name: rule.Name, re: re, action: action})
+		}
+	}
+	return s, nil
+}
+
+func (s *regexScanner) Scan(ctx context.Context, req Request) (*Result, error) {
+	var content any
+	if err := json.Unmarshal(req.Content, &content); err != nil {
+		return nil, fmt.Errorf("failed to parse inspected content: %w", err)
+	}
+
+	result := &Result{Action: config.InspectionActionAllow}
+	redacted := false
+
+	for _, rule := range s.rules {
+		matched := false
+		content = mapStrings(content, func(v string) string {
+			if !rule.re.MatchString(v) {
+				return v
+			}
+
+			matched = true
+			if rule.action == config.InspectionActionRedact {
+				redacted = true
+				return rule.re.ReplaceAllString(v, "[REDACTED]")
+			}
+			return v
+		})
+
+		if matched {
+			result.Findings = append(result.Findings, Finding{Scanner: rule.name, Message: "content matches pattern"})
+			if rule.action.Severity() > result.Action.Severity() {
+				result.Action = rule.action
+			}
+		}
+	}
+
+	if redacted {
+		if data, err := json.Marshal(content); err != nil {
+			return nil, err
+		} else {
+			result.Content = data
+		}
+	}
+
+	return result, nil
+}
+
+// mapStrings replaces all strings in a decoded JSON value with the result of f.
+func mapStrings(v any, f func(string) string) any {
+	switch v := v.(type) {
+	case string:
+		return f(v)
+	case map[string]any:
+		for key, elem := range v {
+			v[key] = mapStrings(elem, f)
+		}
+	case []any:
+		for i, elem := range v {
+			v[i] = mapStrings(elem, f)
+		}
+	}
+	return v
+}
@@ -0,0 +1,214 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/hyprmcp/mcp-gateway/config"
+	"github.com/hyprmcp/mcp-gateway/log"
+	"github.com/lestrrat-go/httprc/v3"
+	"github.com/lestrrat-go/httprc/v3/errsink"
+	"github.com/lestrrat-go/httprc/v3/tracesink"
+	"github.com/lestrrat-go/jwx/v3/jwk"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// authServer holds the state that is needed to work with an authorization server, i.e. its metadata, the cached JWKS
+// and, if dynamic client registration is enabled, the registration backend.
+//
+// An authServer is expensive to create, so it is shared between Managers as long as its configuration does not change.
+type authServer struct {
+	cancel    context.CancelFunc
+	server    string
+	dexConfig *config.DexGRPCClient
+	metadata  *MetadataCache
+	jwkCache  *jwk.Cache
+	// jwks is replaced when the jwks_uri in the metadata changes.
+	jwks        atomic.Pointer[jwks]
+	lastRefresh atomic.Pointer[time.Time]
+	// registry is nil if dynamic client registration is disabled.
+	registry           *ClientRegistry
+	registrationConfig config.DynamicClientRegistration
+}
+
+// jwks is the key set of an authorization server together with its URL.
+type jwks struct {
+	uri string
+	set jwk.Set
+}
+
+// newAuthServer creates a new authServer. All background tasks are stopped and all connections are closed when ctx
+// is canceled or stop is called.
+func newAuthServer(ctx context.Context, scope authorizationScope) (*authServer, error) {
+	log := log.Get(ctx)
+
+	ctx, cancel := context.WithCancel(ctx)
+	as := &authServer{cancel: cancel, server: scope.authorization.Server}
+
+	if err := as.init(ctx, scope); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	log.Info("Authorization server initialized", "server", as.server)
+	return as, nil
+}
+
+func (as *authServer) init(ctx context.Context, scope authorizationScope) error {
+	log := log.Get(ctx)
+
+	if cache, err := jwk.NewCache(ctx, httprc.NewClient(
+		httprc.WithTraceSink(tracesink.Func(func(ctx context.Context, s string) { log.V(1).Info(s) })),
+		httprc.WithErrorSink(errsink.NewFunc(func(ctx context.Context, err error) { log.V(1).Error(err, "httprc.NewClient error") })),
+	)); err != nil {
+		return fmt.Errorf("jwk cache creation error: %w", err)
+	} else if metadata, err := NewMetadataCache(as.server, scope.authorization.GetMetadataCacheTTL()); err != nil {
+		return fmt.Errorf("authorization server metadata error: %w", err)
+	} else {
+		as.jwkCache = cache
+		as.metadata = metadata
+		if err := as.updateJWKS(ctx, metadata.Get()); err != nil {
+			return err
+		}
+		go metadata.Run(ctx, func(metadata map[string]any) {
+			if err := as.updateJWKS(ctx, metadata); err != nil {
+				log.Error(err, "failed to update jwks, using previous jwks", "server", as.server)
+			}
+		})
+	}
+
+	if dcr := scope.authorization.GetDynamicClientRegistration(); dcr.Enabled {
+		if store, err := newFileClientStore(dcr.StoreFile); err != nil {
+			return err
+		} else if backend, err := newRegistrationBackend(ctx, scope, store); err != nil {
+			return fmt.Errorf("registration backend error: %w", err)
+		} else {
+			as.dexConfig = scope.dexGRPCClient
+			as.registry = NewClientRegistry(backend, store, dcr)
+			as.registrationConfig = dcr
+			go as.registry.runPruning(ctx)
+		}
+	}
+
+	return nil
+}
+
+// updateJWKS registers the jwks_uri of the metadata in the JWK cache and uses its key set to verify tokens, if it
+// differs from the current jwks_uri.
+func (as *authServer) updateJWKS(ctx context.Context, metadata map[string]any) error {
+	jwksURI, ok := metadata["jwks_uri"].(string)
+	if !ok {
+		return errors.New("no jwks_uri")
+	}
+
+	current := as.jwks.Load()
+	if current != nil && current.uri == jwksURI {
+		return nil
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if err := as.jwkCache.Register(
+		timeoutCtx,
+		jwksURI,
+		jwk.WithMinInterval(10*time.Second),
+		jwk.WithMaxInterval(5*time.Minute),
+	); err != nil {
+		return fmt.Errorf("jwks registration error: %w", err)
+	} else if _, err := as.jwkCache.Refresh(timeoutCtx, jwksURI); err != nil {
+		return fmt.Errorf("jwks refresh error: %w", err)
+	} else if s, err := as.jwkCache.CachedSet(jwksURI); err != nil {
+		return fmt.Errorf("jwks cache set error: %w", err)
+	} else {
+		as.jwks.Store(&jwks{uri: jwksURI, set: s})
+		as.lastRefresh.Store(ptr(time.Now()))
+	}
+
+	if current != nil {
+		log.Get(ctx).Info("jwks_uri of authorization server changed", "server", as.server, "jwksURI", jwksURI)
+		if err := as.jwkCache.Unregister(ctx, current.uri); err != nil {
+			log.Get(ctx).Error(err, "failed to unregister previous jwks_uri", "jwksURI", current.uri)
+		}
+	}
+
+	return nil
+}
+
+// keySet returns the current key set of the authorization server.
+func (as *authServer) keySet() jwk.Set {
+	return as.jwks.Load().set
+}
+
+func (as *authServer) stop() {
+	log.Root().Info("Stopping authorization server", "server", as.server)
+	as.cancel()
+}
+
+// reusableFor returns true if the authServer can be used for the given scope without changes.
+func (as *authServer) reusableFor(scope authorizationScope) bool {
+	if as.server != scope.authorization.Server || as.metadata.ttl != scope.authorization.GetMetadataCacheTTL() {
+		return false
+	} else if dcr := scope.authorization.GetDynamicClientRegistration(); !dcr.Enabled {
+		return true
+	} else if as.registry == nil || !reflect.DeepEqual(as.registrationConfig, dcr) {
+		return false
+	} else if dcr.GetBackend() != config.RegistrationBackendDex {
+		return true
+	} else {
+		return as.dexConfig != nil && scope.dexGRPCClient != nil && *as.dexConfig == *scope.dexGRPCClient
+	}
+}
+
+func (as *authServer) jwksStatus(ctx context.Context) JWKSStatus {
+	current := as.jwks.Load()
+	status := JWKSStatus{URL: current.uri, KeyIDs: []string{}, LastRefresh: *as.lastRefresh.Load()}
+
+	for i := range current.set.Len() {
+		if key, ok := current.set.Key(i); ok {
+			if kid, ok := key.KeyID(); ok {
+				status.KeyIDs = append(status.KeyIDs, kid)
+			}
+		}
+	}
+
+	if resource, err := as.jwkCache.LookupResource(ctx, current.uri); err != nil {
+		log.Get(ctx).Error(err, "jwks resource lookup error")
+	} else {
+		status.NextRefresh = resource.Next()
+	}
+
+	return status
+}
+
+func (as *authServer) refreshJWKS(ctx context.Context) error {
+	if _, err := as.jwkCache.Refresh(ctx, as.jwks.Load().uri); err != nil {
+		return fmt.Errorf("jwks refresh error: %w", err)
+	}
+
+	as.lastRefresh.Store(ptr(time.Now()))
+	return nil
+}
+
+func newDexGRPCConn(cfg *config.DexGRPCClient) (*grpc.ClientConn, error) {
+	clientTLSConfig, err := cfg.ClientTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var creds credentials.TransportCredentials
+
+	if clientTLSConfig != nil {
+		creds = credentials.NewTLS(clientTLSConfig)
+	} else {
+		creds = insecure.NewCredentials()
+	}
+
+	return grpc.NewClient(cfg.Addr, grpc.WithTransportCredentials(creds))
+}
@@ -1,6 +1,7 @@
 package oauth
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -9,34 +10,185 @@ import (
 	"strings"
 
 	"github.com/hyprmcp/mcp-gateway/config"
+	"github.com/hyprmcp/mcp-gateway/htmlresponse"
+	"github.com/hyprmcp/mcp-gateway/log"
 )
 
 const AuthorizationPath = "/oauth/authorize"
 
-func NewAuthorizationHandler(config *config.Config, meta map[string]any) (http.Handler, error) {
-	supportedScopes := getSupportedScopes(meta)
-	var requiredScopes = slices.DeleteFunc(
-		[]string{"openid", "profile", "email"},
-		func(s string) bool { return !slices.Contains(supportedScopes, s) },
-	)
+// NewAuthorizationHandler redirects authorization requests to the authorization server after adding the scopes that
+// are required by the gateway. Requests must use the authorization code flow with PKCE (S256) and a state, otherwise
+// an error page is shown instead. If registry is not nil, the redirect URI is checked against the registered client,
+// the use of the client is recorded and, if the registration backend issues its own clients, the client ID is replaced
+// with the ID of the upstream client. If client ID metadata documents are enabled, a client ID that is the URL of such
+// a document is replaced with the ID of a client that is registered for it. If consent is enabled, users have to
+// approve the request on a consent page first.
+func NewAuthorizationHandler(
+	config *config.Config,
+	auth *config.Authorization,
+	prefix string,
+	metadata *MetadataCache,
+	registry *ClientRegistry,
+) (http.Handler, error) {
+	var consent *consentPage
+	if auth.GetConsent().Enabled {
+		consent = newConsentPage(auth.GetConsent(), AuthorizationPath+prefix, config.Host.Scheme == "https",
+			protectedResources(config, prefix))
+	}
+
+	server, _ := url.Parse(config.Host.String())
+	server.Path = prefix
+
+	if _, err := authorizationEndpoint(metadata.Get()); err != nil {
+		return nil, err
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the metadata is looked up for every request, because it might change when it is refreshed
+		meta := metadata.Get()
+		redirectURI, err := authorizationEndpoint(meta)
+		if err != nil {
+			writeAuthorizationError(w, r, &authorizationError{
+				status:      http.StatusInternalServerError,
+				description: "The authorization server is not available.",
+				cause:       err,
+			})
+			return
+		}
+
+		supportedScopes := getSupportedScopes(meta)
+		requiredScopes := slices.DeleteFunc(
+			[]string{"openid", "profile", "email"},
+			func(s string) bool { return !slices.Contains(supportedScopes, s) },
+		)
+
+		q := r.URL.Query()
+		if err := checkAuthorizationRequest(r.Context(), registry, q); err != nil {
+			writeAuthorizationError(w, r, err)
+			return
+		}
+
+		scopes := q.Get("scope")
+		for _, scope := range requiredScopes {
+			if !strings.Contains(scopes, scope) {
+				scopes = strings.TrimSpace(scopes + " " + scope)
+			}
+		}
+		q.Set("scope", scopes)
+		if consent != nil && !consent.handle(w, r, registry, q, server.String()) {
+			return
+		}
+		clientID := q.Get("client_id")
+		if registry != nil {
+			registry.Touch(r.Context(), clientID)
+		}
+		if registry.mapsClientIDs() {
+			if upstreamID, _, _, err := registry.resolveUpstreamClient(r.Context(), clientID); err != nil {
+				writeAuthorizationError(w, r, &authorizationError{
+					status:      http.StatusBadRequest,
+					description: "The client is unknown.",
+					cause:       err,
+				})
+				return
+			} else {
+				q.Set("client_id", upstreamID)
+			}
+		}
+		redirectURI.RawQuery = q.Encode()
+		http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+	}), nil
+}
 
-	if authorizationEndpointStr, ok := meta["authorization_endpoint"].(string); !ok {
+// authorizationEndpoint returns the authorization endpoint of the authorization server metadata.
+func authorizationEndpoint(metadata map[string]any) (*url.URL, error) {
+	if endpoint, ok := metadata["authorization_endpoint"].(string); !ok {
 		return nil, errors.New("authorization metadata is missing authorization_endpoint field")
-	} else if _, err := url.Parse(authorizationEndpointStr); err != nil {
+	} else if u, err := url.Parse(endpoint); err != nil {
 		return nil, fmt.Errorf("could not parse authorization endpoint: %w", err)
 	} else {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			redirectURI, _ := url.Parse(authorizationEndpointStr)
-			q := r.URL.Query()
-			scopes := q.Get("scope")
-			for _, scope := range requiredScopes {
-				if !strings.Contains(scopes, scope) {
-					scopes = strings.TrimSpace(scopes + " " + scope)
-				}
+		return u, nil
+	}
+}
+
+// authorizationError is an invalid authorization request. It is shown to the user instead of being sent to the
+// redirect URI, because the redirect URI itself might not be trustworthy.
+type authorizationError struct {
+	status      int
+	description string
+	cause       error
+}
+
+func (e *authorizationError) Error() string {
+	return e.description
+}
+
+func invalidAuthorizationRequest(format string, args ...any) *authorizationError {
+	return &authorizationError{status: http.StatusBadRequest, description: fmt.Sprintf(format, args...)}
+}
+
+// checkAuthorizationRequest returns an *authorizationError if the parameters of the authorization request are not
+// acceptable. The client for a client ID metadata document is registered as a side effect.
+func checkAuthorizationRequest(ctx context.Context, registry *ClientRegistry, q url.Values) *authorizationError {
+	clientID, redirectURI := q.Get("client_id"), q.Get("redirect_uri")
+	if clientID == "" {
+		return invalidAuthorizationRequest("The client_id parameter is missing.")
+	} else if redirectURI == "" {
+		return invalidAuthorizationRequest("The redirect_uri parameter is missing.")
+	}
+
+	var registrationErr *RegistrationError
+	if registry.supportsMetadataDocuments() && isMetadataDocumentClientID(clientID) {
+		if _, err := registry.registerMetadataDocumentClient(ctx, clientID, redirectURI); errors.As(err, &registrationErr) {
+			return invalidAuthorizationRequest("The client metadata document can not be used: %v", registrationErr.Description)
+		} else if err != nil {
+			return &authorizationError{
+				status:      http.StatusInternalServerError,
+				description: "The client could not be registered.",
+				cause:       err,
+			}
+		}
+	} else if registry != nil {
+		// clients that were not registered with the gateway are checked by the authorization server
+		if err := registry.CheckRedirectURI(ctx, clientID, redirectURI); errors.As(err, &registrationErr) {
+			return invalidAuthorizationRequest("The redirect URI %v is not registered for this client.", redirectURI)
+		} else if err != nil && !errors.Is(err, ErrClientNotFound) {
+			return &authorizationError{
+				status:      http.StatusInternalServerError,
+				description: "The client could not be checked.",
+				cause:       err,
 			}
-			q.Set("scope", scopes)
-			redirectURI.RawQuery = q.Encode()
-			http.Redirect(w, r, redirectURI.String(), http.StatusFound)
-		}), nil
+		}
+	}
+
+	if responseType := q.Get("response_type"); responseType == "" {
+		return invalidAuthorizationRequest("The response_type parameter is missing.")
+	} else if responseType != "code" {
+		return invalidAuthorizationRequest("The response type %q is not supported.", responseType)
+	}
+
+	if q.Get("state") == "" {
+		return invalidAuthorizationRequest("The state parameter is missing.")
 	}
+
+	if q.Get("code_challenge") == "" {
+		return invalidAuthorizationRequest("The code_challenge parameter is missing. PKCE is required.")
+	} else if method := q.Get("code_challenge_method"); method != "S256" {
+		return invalidAuthorizationRequest("The code challenge method %q is not supported, S256 is required.", method)
+	}
+
+	return nil
+}
+
+func writeAuthorizationError(w http.ResponseWriter, r *http.Request, err *authorizationError) {
+	hint := "The application that sent you here made an invalid request. " +
+		"Please contact its developer if the problem persists."
+	if err.status >= http.StatusInternalServerError {
+		log.Get(r.Context()).Error(err.cause, "authorization request failed", "client_id", r.URL.Query().Get("client_id"))
+		hint = "Please try again later."
+	} else {
+		log.Get(r.Context()).Info("invalid authorization request", "client_id", r.URL.Query().Get("client_id"),
+			"error", err.description)
+	}
+
+	htmlresponse.WriteError(w, err.status, "Authorization failed", err.description, hint)
 }
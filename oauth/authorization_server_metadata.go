@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/hyprmcp/mcp-gateway/config"
 	"github.com/hyprmcp/mcp-gateway/log"
@@ -16,33 +18,54 @@ import (
 const AuthorizationServerMetadataPath = "/.well-known/oauth-authorization-server"
 const OIDCMetadataPath = "/.well-known/openid-configuration"
 
-func NewAuthorizationServerMetadataHandler(config *config.Config) http.Handler {
-	if len(config.Proxy) == 1 && !config.Proxy[0].Authentication.Enabled {
+// metadataHTTPClient is used to fetch the metadata of authorization servers.
+var metadataHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// NewAuthorizationServerMetadataHandler serves the cached metadata of the given authorization server. The registration
+// and authorization endpoints are replaced with the gateway's endpoints for the given prefix, if they are enabled.
+func NewAuthorizationServerMetadataHandler(
+	config *config.Config,
+	auth *config.Authorization,
+	prefix string,
+	cache *MetadataCache,
+) http.Handler {
+	if prefix == "" && len(config.Proxy) == 1 && !config.Proxy[0].Authentication.Enabled {
 		return &httputil.ReverseProxy{
 			Rewrite:        proxyutil.RewriteHostFunc((*url.URL)(config.Proxy[0].Http.Url)),
 			ModifyResponse: proxyutil.RemoveCORSHeaders,
 		}
 	} else {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			metadata, err := GetMedatata(config.Authorization.Server)
-			if err != nil {
-				log.Get(r.Context()).Error(err, "failed to get authorization server metadata from upstream")
-				http.Error(w, "Failed to retrieve authorization server metadata", http.StatusInternalServerError)
-			}
+			metadata := cache.Get()
 
-			if config.Authorization.GetDynamicClientRegistration().Enabled {
-				if _, ok := metadata["registration_endpoint"]; !ok {
+			if dcr := auth.GetDynamicClientRegistration(); dcr.Enabled {
+				// Dex never advertises a registration endpoint, other authorization servers might have their own
+				if _, ok := metadata["registration_endpoint"]; !ok || !isDexBackend(dcr) {
 					registrationURI, _ := url.Parse(config.Host.String())
-					registrationURI.Path = DynamicClientRegistrationPath
+					registrationURI.Path = DynamicClientRegistrationPath + prefix
 					metadata["registration_endpoint"] = registrationURI.String()
 					log.Get(r.Context()).Info("Adding registration endpoint to authorization server metadata",
 						"url", metadata["registration_endpoint"])
 				}
 			}
 
-			if config.Authorization.AuthorizationProxyEnabled {
+			cimd := auth.GetDynamicClientRegistration().GetClientIDMetadataDocument()
+			if usesGatewayClients(auth) || cimd.Enabled {
+				tokenURI, _ := url.Parse(config.Host.String())
+				tokenURI.Path = TokenPath + prefix
+				metadata["token_endpoint"] = tokenURI.String()
+				if usesGatewayClients(auth) {
+					metadata["token_endpoint_auth_methods_supported"] = []string{"none"}
+				}
+			}
+
+			if cimd.Enabled {
+				metadata["client_id_metadata_document_supported"] = true
+			}
+
+			if auth.AuthorizationProxyEnabled {
 				authorizationURI, _ := url.Parse(config.Host.String())
-				authorizationURI.Path = AuthorizationPath
+				authorizationURI.Path = AuthorizationPath + prefix
 				metadata["authorization_endpoint"] = authorizationURI.String()
 				log.Get(r.Context()).Info("Adding authorization endpoint to authorization server metadata",
 					"url", metadata["authorization_endpoint"])
@@ -56,6 +79,8 @@ func NewAuthorizationServerMetadataHandler(config *config.Config) http.Handler {
 	}
 }
 
+// GetMedatata fetches the metadata of the given authorization server from its well-known URIs. The issuer in the
+// metadata must match the server as required by RFC 8414, Section 3.3.
 func GetMedatata(server string) (map[string]any, error) {
 	uris, err := getMetadataURIs(server)
 	if err != nil {
@@ -63,7 +88,7 @@ func GetMedatata(server string) (map[string]any, error) {
 	}
 
 	getMetatadatFunc := func(u string) (map[string]any, error) {
-		resp, err := http.Get(u)
+		resp, err := metadataHTTPClient.Get(u)
 		if err != nil {
 			return nil, err
 		}
@@ -79,6 +104,11 @@ func GetMedatata(server string) (map[string]any, error) {
 			return nil, err
 		}
 
+		// a trailing slash is tolerated, because it is often added or omitted in the configuration
+		if issuer, _ := metadata["issuer"].(string); strings.TrimSuffix(issuer, "/") != strings.TrimSuffix(server, "/") {
+			return nil, fmt.Errorf("issuer %q in %v does not match authorization server %v", issuer, u, server)
+		}
+
 		return metadata, nil
 	}
 
@@ -93,17 +123,29 @@ func GetMedatata(server string) (map[string]any, error) {
 	return nil, err
 }
 
+// getMetadataURIs returns the URIs at which the metadata of the given issuer is discovered, in the order in which they
+// should be tried. The well-known paths are inserted between the host and the path of the issuer as defined in
+// RFC 8414, Section 3.1. For issuers with a path, OpenID Connect Discovery with the well-known path appended to the
+// issuer is tried last.
 func getMetadataURIs(server string) ([]string, error) {
-	var uris []string
-
 	serverURL, err := url.Parse(server)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse authorization server URL: %w", err)
 	}
 
-	serverURL.Path = AuthorizationServerMetadataPath
-	uris = append(uris, serverURL.String())
-	serverURL.Path = OIDCMetadataPath
-	uris = append(uris, serverURL.String())
+	serverURL.RawQuery = ""
+	serverURL.Fragment = ""
+	serverURL.Path = strings.TrimSuffix(serverURL.Path, "/")
+	serverURL.RawPath = strings.TrimSuffix(serverURL.RawPath, "/")
+
+	uris := []string{
+		wellKnownURL(serverURL, AuthorizationServerMetadataPath).String(),
+		wellKnownURL(serverURL, OIDCMetadataPath).String(),
+	}
+
+	if serverURL.Path != "" {
+		uris = append(uris, serverURL.JoinPath(OIDCMetadataPath).String())
+	}
+
 	return uris, nil
 }
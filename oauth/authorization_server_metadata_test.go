@@ -0,0 +1,216 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetMetadataURIs(t *testing.T) {
+	tests := []struct {
+		server string
+		want   []string
+	}{
+		{
+			"https://auth.example.com",
+			[]string{
+				"https://auth.example.com/.well-known/oauth-authorization-server",
+				"https://auth.example.com/.well-known/openid-configuration",
+			},
+		},
+		{
+			"https://auth.example.com/",
+			[]string{
+				"https://auth.example.com/.well-known/oauth-authorization-server",
+				"https://auth.example.com/.well-known/openid-configuration",
+			},
+		},
+		{
+			"https://auth.example.com/realms/mcp",
+			[]string{
+				"https://auth.example.com/.well-known/oauth-authorization-server/realms/mcp",
+				"https://auth.example.com/.well-known/openid-configuration/realms/mcp",
+				"https://auth.example.com/realms/mcp/.well-known/openid-configuration",
+			},
+		},
+		{
+			"https://auth.example.com/realms/mcp/?query#fragment",
+			[]string{
+				"https://auth.example.com/.well-known/oauth-authorization-server/realms/mcp",
+				"https://auth.example.com/.well-known/openid-configuration/realms/mcp",
+				"https://auth.example.com/realms/mcp/.well-known/openid-configuration",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.server, func(t *testing.T) {
+			if got, err := getMetadataURIs(tt.server); err != nil {
+				t.Fatal(err)
+			} else if !slices.Equal(got, tt.want) {
+				t.Errorf("getMetadataURIs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWellKnownURL(t *testing.T) {
+	tests := []struct {
+		resource string
+		want     string
+	}{
+		{"https://gateway.example.com", "https://gateway.example.com/.well-known/oauth-protected-resource"},
+		{"https://gateway.example.com/", "https://gateway.example.com/.well-known/oauth-protected-resource"},
+		{"https://gateway.example.com/mcp", "https://gateway.example.com/.well-known/oauth-protected-resource/mcp"},
+		{"https://gateway.example.com/a/b/", "https://gateway.example.com/.well-known/oauth-protected-resource/a/b/"},
+		{"https://gateway.example.com/a%2Fb", "https://gateway.example.com/.well-known/oauth-protected-resource/a%2Fb"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.resource, func(t *testing.T) {
+			u, _ := url.Parse(tt.resource)
+			if got := wellKnownURL(u, ProtectedResourcePath).String(); got != tt.want {
+				t.Errorf("wellKnownURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetMetadata(t *testing.T) {
+	// documents maps the paths of the test server to the issuers in the metadata served at them, in which {server} is
+	// replaced with the URL of the test server
+	var documents map[string]string
+	var serverURL string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if issuer, ok := documents[r.URL.Path]; !ok {
+			http.NotFound(w, r)
+		} else {
+			issuer = strings.ReplaceAll(issuer, "{server}", serverURL)
+			_ = json.NewEncoder(w).Encode(map[string]any{"issuer": issuer, "path": r.URL.Path})
+		}
+	}))
+	defer server.Close()
+	serverURL = server.URL
+
+	tests := []struct {
+		name      string
+		path      string
+		documents map[string]string
+		wantPath  string
+		wantErr   bool
+	}{
+		{
+			name:      "oauth metadata",
+			documents: map[string]string{"/.well-known/oauth-authorization-server": "{server}"},
+			wantPath:  "/.well-known/oauth-authorization-server",
+		},
+		{
+			name:      "issuer with trailing slash",
+			documents: map[string]string{"/.well-known/oauth-authorization-server": "{server}/"},
+			wantPath:  "/.well-known/oauth-authorization-server",
+		},
+		{
+			name:      "openid configuration",
+			documents: map[string]string{"/.well-known/openid-configuration": "{server}"},
+			wantPath:  "/.well-known/openid-configuration",
+		},
+		{
+			name:      "issuer with path",
+			path:      "/realms/mcp",
+			documents: map[string]string{"/.well-known/oauth-authorization-server/realms/mcp": "{server}/realms/mcp"},
+			wantPath:  "/.well-known/oauth-authorization-server/realms/mcp",
+		},
+		{
+			name:      "openid configuration appended to issuer with path",
+			path:      "/realms/mcp",
+			documents: map[string]string{"/realms/mcp/.well-known/openid-configuration": "{server}/realms/mcp"},
+			wantPath:  "/realms/mcp/.well-known/openid-configuration",
+		},
+		{
+			name: "mismatching issuer is skipped",
+			documents: map[string]string{
+				"/.well-known/oauth-authorization-server": "https://evil.example.com",
+				"/.well-known/openid-configuration":       "{server}",
+			},
+			wantPath: "/.well-known/openid-configuration",
+		},
+		{
+			name:      "mismatching issuer",
+			documents: map[string]string{"/.well-known/oauth-authorization-server": "https://evil.example.com"},
+			wantErr:   true,
+		},
+		{
+			name:      "issuer of other path",
+			path:      "/realms/mcp",
+			documents: map[string]string{"/.well-known/oauth-authorization-server/realms/mcp": "{server}/realms/other"},
+			wantErr:   true,
+		},
+		{
+			name:      "missing issuer",
+			documents: map[string]string{"/.well-known/oauth-authorization-server": ""},
+			wantErr:   true,
+		},
+		{
+			name:    "no metadata",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			documents = tt.documents
+			metadata, err := GetMedatata(server.URL + tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("GetMedatata() = %v, want error", metadata)
+				}
+			} else if err != nil {
+				t.Errorf("GetMedatata() error = %v", err)
+			} else if metadata["path"] != tt.wantPath {
+				t.Errorf("GetMedatata() fetched %v, want %v", metadata["path"], tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestMetadataCacheRefresh(t *testing.T) {
+	tokenEndpoint := "https://auth.example.com/token"
+	var serverURL string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"issuer": serverURL, "token_endpoint": tokenEndpoint})
+	}))
+	defer server.Close()
+	serverURL = server.URL
+
+	cache, err := NewMetadataCache(server.URL, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokenEndpoint = "https://auth.example.com/rotated"
+	refreshed := make(chan map[string]any, 1)
+	go cache.Run(t.Context(), func(metadata map[string]any) {
+		select {
+		case refreshed <- metadata:
+		default:
+		}
+	})
+
+	select {
+	case metadata := <-refreshed:
+		if metadata["token_endpoint"] != tokenEndpoint {
+			t.Errorf("refreshed token_endpoint = %v, want %v", metadata["token_endpoint"], tokenEndpoint)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("metadata was not refreshed")
+	}
+
+	if got := cache.Get()["token_endpoint"]; got != tokenEndpoint {
+		t.Errorf("Get() token_endpoint = %v, want %v", got, tokenEndpoint)
+	}
+}
@@ -0,0 +1,74 @@
+package oauth
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/hyprmcp/mcp-gateway/config"
+)
+
+func TestCheckAuthorizationRequest(t *testing.T) {
+	ctx := t.Context()
+	store, err := newFileClientStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dcr := config.DynamicClientRegistration{Enabled: true, Backend: config.RegistrationBackendStatic}
+	registry := NewClientRegistry(newStaticRegistrationBackend(&config.StaticRegistration{ClientID: "upstream"}, store), store, dcr)
+	client, _, err := registry.Register(ctx, ClientInformation{
+		RedirectURIs: []string{"https://app.example.com/callback", "http://127.0.0.1:1234/callback"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := url.Values{
+		"client_id":             {client.ClientID},
+		"redirect_uri":          {"https://app.example.com/callback"},
+		"response_type":         {"code"},
+		"state":                 {"state"},
+		"code_challenge":        {"challenge"},
+		"code_challenge_method": {"S256"},
+	}
+
+	tests := []struct {
+		name       string
+		registry   *ClientRegistry
+		modify     func(q url.Values)
+		wantStatus int
+	}{
+		{"valid", registry, func(q url.Values) {}, 0},
+		{"loopback with other port", registry, func(q url.Values) { q.Set("redirect_uri", "http://127.0.0.1:5678/callback") }, 0},
+		{"unknown client without registry", nil, func(q url.Values) { q.Set("client_id", "other") }, 0},
+		{"unknown client", registry, func(q url.Values) { q.Set("client_id", "other") }, 0},
+		{"missing client_id", registry, func(q url.Values) { q.Del("client_id") }, http.StatusBadRequest},
+		{"missing redirect_uri", registry, func(q url.Values) { q.Del("redirect_uri") }, http.StatusBadRequest},
+		{"unregistered redirect_uri", registry, func(q url.Values) { q.Set("redirect_uri", "https://evil.com/callback") }, http.StatusBadRequest},
+		{"loopback with other path", registry, func(q url.Values) { q.Set("redirect_uri", "http://127.0.0.1:1234/other") }, http.StatusBadRequest},
+		{"missing response_type", registry, func(q url.Values) { q.Del("response_type") }, http.StatusBadRequest},
+		{"implicit flow", registry, func(q url.Values) { q.Set("response_type", "token") }, http.StatusBadRequest},
+		{"missing state", registry, func(q url.Values) { q.Del("state") }, http.StatusBadRequest},
+		{"missing code_challenge", registry, func(q url.Values) { q.Del("code_challenge") }, http.StatusBadRequest},
+		{"missing code_challenge_method", registry, func(q url.Values) { q.Del("code_challenge_method") }, http.StatusBadRequest},
+		{"plain code_challenge_method", registry, func(q url.Values) { q.Set("code_challenge_method", "plain") }, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := url.Values{}
+			for k, v := range valid {
+				q[k] = append([]string(nil), v...)
+			}
+			tt.modify(q)
+
+			err := checkAuthorizationRequest(ctx, tt.registry, q)
+			if tt.wantStatus == 0 && err != nil {
+				t.Errorf("checkAuthorizationRequest() = %v, want nil", err)
+			} else if tt.wantStatus != 0 && (err == nil || err.status != tt.wantStatus) {
+				t.Errorf("checkAuthorizationRequest() = %v, want status %v", err, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hyprmcp/mcp-gateway/config"
+)
+
+const (
+	defaultMetadataDocumentCacheTTL = time.Hour
+	// maxMetadataDocumentSize is the size limit for client metadata documents recommended by the specification.
+	maxMetadataDocumentSize = 5 * 1024
+)
+
+var errPrivateNetwork = errors.New("address is in a private network")
+
+// isMetadataDocumentClientID returns true if clientID is the URL of a client metadata document, i.e. an https URL
+// with a path and without fragment or credentials.
+func isMetadataDocumentClientID(clientID string) bool {
+	u, err := url.Parse(clientID)
+	return err == nil && u.Scheme == "https" && u.Host != "" && u.Path != "" && u.Path != "/" &&
+		u.Fragment == "" && u.User == nil
+}
+
+// metadataDocumentFetcher fetches and caches client metadata documents.
+type metadataDocumentFetcher struct {
+	config     config.ClientIDMetadataDocument
+	ttl        time.Duration
+	httpClient *http.Client
+
+	mu    sync.Mutex
+	cache map[string]cachedMetadataDocument
+}
+
+type cachedMetadataDocument struct {
+	document *ClientInformation
+	expires  time.Time
+}
+
+func newMetadataDocumentFetcher(cfg config.ClientIDMetadataDocument) *metadataDocumentFetcher {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !cfg.AllowPrivateNetworks {
+		// the address is checked after name resolution, so that DNS names that point to private addresses are rejected
+		dialer.Control = func(network, address string, c syscall.RawConn) error {
+			if host, _, err := net.SplitHostPort(address); err != nil {
+				return err
+			} else if ip := net.ParseIP(host); ip == nil || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+				return fmt.Errorf("%v: %w", address, errPrivateNetwork)
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	f := &metadataDocumentFetcher{
+		config: cfg,
+		ttl:    cfg.CacheTTL,
+		httpClient: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return errors.New("client metadata documents must not redirect")
+			},
+		},
+		cache: map[string]cachedMetadataDocument{},
+	}
+
+	if f.ttl == 0 {
+		f.ttl = defaultMetadataDocumentCacheTTL
+	}
+
+	return f
+}
+
+// fetch returns the client metadata document at the given URL, which must also be the client ID.
+func (f *metadataDocumentFetcher) fetch(ctx context.Context, clientID string) (*ClientInformation, error) {
+	f.mu.Lock()
+	cached, ok := f.cache[clientID]
+	f.mu.Unlock()
+
+	if ok && time.Now().Before(cached.expires) {
+		return cached.document, nil
+	}
+
+	if u, err := url.Parse(clientID); err != nil {
+		return nil, err
+	} else if len(f.config.AllowedHosts) > 0 && !hostAllowed(u.Hostname(), f.config.AllowedHosts) {
+		return nil, fmt.Errorf("host %v is not allowed", u.Hostname())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, clientID, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %v: %v", clientID, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxMetadataDocumentSize+1))
+	if err != nil {
+		return nil, err
+	} else if len(data) > maxMetadataDocumentSize {
+		return nil, fmt.Errorf("client metadata document is larger than %v bytes", maxMetadataDocumentSize)
+	}
+
+	var document struct {
+		ClientInformation
+		ClientSecret string `json:"client_secret"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("client metadata document is invalid: %w", err)
+	} else if document.ClientID != clientID {
+		return nil, fmt.Errorf("client_id %q of client metadata document does not match its URL", document.ClientID)
+	} else if document.ClientSecret != "" {
+		return nil, errors.New("client metadata document must not contain a client_secret")
+	} else if method := document.TokenEndpointAuthMethod; method != "" && method != "none" {
+		return nil, fmt.Errorf("token_endpoint_auth_method %q is not supported for client metadata documents", method)
+	}
+
+	f.mu.Lock()
+	for k, v := range f.cache {
+		if time.Now().After(v.expires) {
+			delete(f.cache, k)
+		}
+	}
+	f.cache[clientID] = cachedMetadataDocument{document: &document.ClientInformation, expires: time.Now().Add(f.ttl)}
+	f.mu.Unlock()
+
+	return &document.ClientInformation, nil
+}
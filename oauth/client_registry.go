@@ -0,0 +1,362 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/hyprmcp/mcp-gateway/config"
+	"github.com/hyprmcp/mcp-gateway/log"
+)
+
+// lastUsedResolution limits how often the last use of a client is written to the ClientStore.
+const lastUsedResolution = time.Hour
+
+var errInvalidRegistrationToken = errors.New("invalid registration access token")
+
+// ClientRegistry creates dynamically registered clients with a RegistrationBackend and keeps track of them in a
+// ClientStore, so that they can be managed by their owners and deleted when they are no longer used.
+type ClientRegistry struct {
+	backend RegistrationBackend
+	store   ClientStore
+	policy  *registrationPolicy
+	// documents is nil if client ID metadata documents are disabled.
+	documents *metadataDocumentFetcher
+	// expiry is the time after which unused clients are deleted, or zero if they are never deleted.
+	expiry time.Duration
+	mu     sync.Mutex
+}
+
+func NewClientRegistry(backend RegistrationBackend, store ClientStore, dcr config.DynamicClientRegistration) *ClientRegistry {
+	cr := &ClientRegistry{
+		backend: backend,
+		store:   store,
+		policy:  newRegistrationPolicy(dcr),
+		expiry:  dcr.UnusedClientExpiry,
+	}
+	if cimd := dcr.GetClientIDMetadataDocument(); cimd.Enabled {
+		cr.documents = newMetadataDocumentFetcher(cimd)
+	}
+	return cr
+}
+
+// Register creates a new client and returns its information together with a new registration access token. If the
+// client metadata violates the registration policy, a *RegistrationError is returned.
+func (cr *ClientRegistry) Register(ctx context.Context, info ClientInformation) (*ClientInformation, string, error) {
+	if err := cr.policy.check(&info); err != nil {
+		return nil, "", err
+	}
+
+	client, err := cr.backend.RegisterClient(ctx, info)
+	if err != nil {
+		return nil, "", err
+	}
+
+	client.GrantTypes = info.GrantTypes
+	client.ResponseTypes = info.ResponseTypes
+	if client.ClientSecret != "" {
+		client.TokenEndpointAuthMethod = info.TokenEndpointAuthMethod
+	}
+
+	token := genRandom()
+	now := time.Now().UTC()
+	registration := Registration{
+		Client:                      *client,
+		RegistrationAccessTokenHash: hashToken(token),
+		CreatedAt:                   now,
+		LastUsedAt:                  now,
+	}
+
+	if err := cr.store.PutRegistration(ctx, registration); err != nil {
+		if err := cr.backend.DeleteClient(ctx, client.ClientID); err != nil {
+			log.Get(ctx).Error(err, "failed to delete client after store error", "client_id", client.ClientID)
+		}
+		return nil, "", err
+	}
+
+	client.ClientIDIssuedAt = now.Unix()
+	return client, token, nil
+}
+
+// Authenticate returns the registration of the client if token is its registration access token.
+func (cr *ClientRegistry) Authenticate(ctx context.Context, clientID, token string) (*Registration, error) {
+	if registration, err := cr.store.GetRegistration(ctx, clientID); err != nil {
+		return nil, err
+	} else if subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(registration.RegistrationAccessTokenHash)) != 1 {
+		return nil, errInvalidRegistrationToken
+	} else {
+		return registration, nil
+	}
+}
+
+// Update replaces the metadata of a registered client. If the client metadata violates the registration policy, a
+// *RegistrationError is returned.
+func (cr *ClientRegistry) Update(ctx context.Context, info ClientInformation) (*ClientInformation, error) {
+	if err := cr.policy.check(&info); err != nil {
+		return nil, err
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	registration, err := cr.store.GetRegistration(ctx, info.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the secret can not be changed by the client
+	info.ClientSecret = registration.Client.ClientSecret
+	client, err := cr.backend.UpdateClient(ctx, info)
+	if err != nil {
+		return nil, err
+	}
+
+	client.GrantTypes = info.GrantTypes
+	client.ResponseTypes = info.ResponseTypes
+	registration.Client = *client
+	registration.LastUsedAt = time.Now().UTC()
+	if err := cr.store.PutRegistration(ctx, *registration); err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// Delete deletes a registered client.
+func (cr *ClientRegistry) Delete(ctx context.Context, clientID string) error {
+	if err := cr.backend.DeleteClient(ctx, clientID); err != nil {
+		return err
+	}
+	return cr.store.DeleteRegistration(ctx, clientID)
+}
+
+// List returns the registrations of all clients.
+func (cr *ClientRegistry) List(ctx context.Context) ([]Registration, error) {
+	return cr.store.ListRegistrations(ctx)
+}
+
+// Touch records that the client with the given ID, which may also be the URL of a client metadata document, was used.
+// Unknown clients are ignored.
+func (cr *ClientRegistry) Touch(ctx context.Context, clientID string) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	if registration, err := cr.registration(ctx, clientID); err != nil {
+		return
+	} else if time.Since(registration.LastUsedAt) < lastUsedResolution {
+		return
+	} else {
+		registration.LastUsedAt = time.Now().UTC()
+		if err := cr.store.PutRegistration(ctx, *registration); err != nil {
+			log.Get(ctx).Error(err, "failed to record client use", "client_id", registration.Client.ClientID)
+		}
+	}
+}
+
+// Prune deletes all clients that have not been used for longer than unusedFor and returns their registrations. If
+// unusedFor is zero, the configured expiry is used. Nothing is deleted if neither is set.
+func (cr *ClientRegistry) Prune(ctx context.Context, unusedFor time.Duration) ([]Registration, error) {
+	if unusedFor <= 0 {
+		unusedFor = cr.expiry
+	}
+	if unusedFor <= 0 {
+		return nil, nil
+	}
+
+	registrations, err := cr.store.ListRegistrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []Registration
+	var errs []error
+	for _, registration := range registrations {
+		if time.Since(registration.LastUsedAt) <= unusedFor {
+			continue
+		} else if err := cr.Delete(ctx, registration.Client.ClientID); err != nil {
+			errs = append(errs, err)
+		} else {
+			log.Get(ctx).Info("Deleted unused client", "client_id", registration.Client.ClientID,
+				"lastUsedAt", registration.LastUsedAt)
+			pruned = append(pruned, registration)
+		}
+	}
+
+	return pruned, errors.Join(errs...)
+}
+
+// runPruning periodically deletes unused clients until ctx is canceled. It does nothing if no expiry is configured.
+func (cr *ClientRegistry) runPruning(ctx context.Context) {
+	if cr.expiry <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(min(cr.expiry, lastUsedResolution))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := cr.Prune(ctx, 0); err != nil {
+				log.Get(ctx).Error(err, "failed to prune unused clients")
+			}
+		}
+	}
+}
+
+// registration returns the registration of the client with the given ID, which may also be the URL of a client
+// metadata document.
+func (cr *ClientRegistry) registration(ctx context.Context, clientID string) (*Registration, error) {
+	if cr.supportsMetadataDocuments() && isMetadataDocumentClientID(clientID) {
+		return cr.metadataDocumentRegistration(ctx, clientID)
+	}
+	return cr.store.GetRegistration(ctx, clientID)
+}
+
+// CheckRedirectURI returns a *RegistrationError if redirectURI is not one of the redirect URIs of the registered
+// client. The port of loopback redirect URIs may differ as described in RFC 8252, Section 7.3. ErrClientNotFound is
+// returned if the client was not registered with the gateway.
+func (cr *ClientRegistry) CheckRedirectURI(ctx context.Context, clientID, redirectURI string) error {
+	registration, err := cr.store.GetRegistration(ctx, clientID)
+	if err != nil {
+		return err
+	}
+
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return invalidRedirectURI("redirect URI %q is invalid", redirectURI)
+	}
+
+	for _, registered := range registration.Client.RedirectURIs {
+		if registered == redirectURI {
+			return nil
+		} else if r, err := url.Parse(registered); err == nil && isLoopbackURI(r) && isLoopbackURI(u) &&
+			r.Hostname() == u.Hostname() && r.Path == u.Path && r.RawQuery == u.RawQuery {
+			return nil
+		}
+	}
+
+	return invalidRedirectURI("redirect URI %q is not registered for the client", redirectURI)
+}
+
+// supportsMetadataDocuments returns true if client ID metadata documents are enabled.
+func (cr *ClientRegistry) supportsMetadataDocuments() bool {
+	return cr != nil && cr.documents != nil
+}
+
+// mapsClientIDs returns true if client IDs that are used by clients must be mapped to different client IDs at the
+// authorization server, either because the registration backend issues its own client IDs or because client ID
+// metadata documents are enabled.
+func (cr *ClientRegistry) mapsClientIDs() bool {
+	if cr == nil {
+		return false
+	}
+	_, ok := cr.backend.(upstreamClientResolver)
+	return ok || cr.documents != nil
+}
+
+// registerMetadataDocumentClient fetches the client metadata document at clientID, checks that it allows redirectURI
+// and returns the registration of the matching client, which is registered with the backend or updated if necessary.
+// Problems with the document or the redirect URI are returned as *RegistrationError.
+func (cr *ClientRegistry) registerMetadataDocumentClient(ctx context.Context, clientID, redirectURI string) (*Registration, error) {
+	document, err := cr.documents.fetch(ctx, clientID)
+	if err != nil {
+		return nil, invalidClientMetadata("client metadata document could not be used: %v", err)
+	} else if !slices.Contains(document.RedirectURIs, redirectURI) {
+		return nil, invalidRedirectURI("redirect URI %q is not allowed by the client metadata document", redirectURI)
+	}
+
+	info := ClientInformation{
+		ClientName:   document.ClientName,
+		RedirectURIs: document.RedirectURIs,
+		LogoURI:      document.LogoURI,
+		GrantTypes:   document.GrantTypes,
+		Scope:        document.Scope,
+	}
+
+	// the token endpoint auth method is not checked, because the gateway authenticates the client at the token endpoint
+	if err := cr.policy.check(&info); err != nil {
+		return nil, err
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	registration, err := cr.metadataDocumentRegistration(ctx, clientID)
+	if errors.Is(err, ErrClientNotFound) {
+		client, err := cr.backend.RegisterClient(ctx, info)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now().UTC()
+		registration = &Registration{Client: *client, MetadataDocumentURL: clientID, CreatedAt: now, LastUsedAt: now}
+		log.Get(ctx).Info("Registered client for client metadata document", "url", clientID, "client_id", client.ClientID)
+	} else if err != nil {
+		return nil, err
+	} else if registration.Client.ClientName != info.ClientName || registration.Client.LogoURI != info.LogoURI ||
+		!slices.Equal(registration.Client.RedirectURIs, info.RedirectURIs) {
+		info.ClientID = registration.Client.ClientID
+		info.ClientSecret = registration.Client.ClientSecret
+		if client, err := cr.backend.UpdateClient(ctx, info); err != nil {
+			return nil, err
+		} else {
+			registration.Client = *client
+			log.Get(ctx).Info("Updated client for client metadata document", "url", clientID, "client_id", client.ClientID)
+		}
+	} else {
+		return registration, nil
+	}
+
+	if err := cr.store.PutRegistration(ctx, *registration); err != nil {
+		return nil, err
+	}
+
+	return registration, nil
+}
+
+func (cr *ClientRegistry) metadataDocumentRegistration(ctx context.Context, clientID string) (*Registration, error) {
+	if registrations, err := cr.store.ListRegistrations(ctx); err != nil {
+		return nil, err
+	} else if idx := slices.IndexFunc(registrations, func(r Registration) bool { return r.MetadataDocumentURL == clientID }); idx < 0 {
+		return nil, ErrClientNotFound
+	} else {
+		return &registrations[idx], nil
+	}
+}
+
+// resolveUpstreamClient returns the ID and secret of the client at the authorization server for the client ID that
+// was sent by a client. If mapped is false, the client ID must be used as is.
+func (cr *ClientRegistry) resolveUpstreamClient(ctx context.Context, clientID string) (id, secret string, mapped bool, err error) {
+	id = clientID
+
+	if cr.supportsMetadataDocuments() && isMetadataDocumentClientID(clientID) {
+		if registration, err := cr.metadataDocumentRegistration(ctx, clientID); err != nil {
+			return "", "", false, err
+		} else {
+			id, secret, mapped = registration.Client.ClientID, registration.Client.ClientSecret, true
+		}
+	}
+
+	if resolver, ok := cr.backend.(upstreamClientResolver); ok {
+		if id, secret, err = resolver.resolveUpstreamClient(ctx, id); err != nil {
+			return "", "", false, err
+		}
+		mapped = true
+	}
+
+	return id, secret, mapped, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,248 @@
+package oauth
+
+import (
+	"cmp"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hyprmcp/mcp-gateway/config"
+	"github.com/hyprmcp/mcp-gateway/htmlresponse"
+	"github.com/hyprmcp/mcp-gateway/log"
+)
+
+const (
+	consentCookieName     = "mcp_gateway_consent"
+	consentCSRFCookieName = "mcp_gateway_consent_csrf"
+	// maxRememberedConsents limits the number of approvals in the consent cookie, so that it stays below the size
+	// limit of browsers.
+	maxRememberedConsents = 20
+)
+
+// consentKey signs consent cookies if no secret is configured. It is shared by all consent pages, so that approvals
+// survive configuration reloads.
+var consentKey = func() []byte {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	return key
+}()
+
+var errInvalidConsentCookie = errors.New("invalid consent cookie")
+
+// consentPage asks users to approve authorization requests and remembers their approvals per client, resource and
+// scopes in a signed cookie. Since users are not authenticated yet, approvals are remembered per browser.
+type consentPage struct {
+	rememberFor time.Duration
+	key         []byte
+	cookiePath  string
+	secure      bool
+	// resources are the URLs of the protected resources that can be authorized on this page.
+	resources []string
+}
+
+func newConsentPage(cfg config.Consent, cookiePath string, secure bool, resources []string) *consentPage {
+	c := &consentPage{
+		rememberFor: cfg.GetRememberFor(),
+		key:         consentKey,
+		cookiePath:  cookiePath,
+		secure:      secure,
+		resources:   resources,
+	}
+	if cfg.Secret != "" {
+		sum := sha256.Sum256([]byte(cfg.Secret))
+		c.key = sum[:]
+	}
+	return c
+}
+
+// approvalKey returns the key under which the approval of an authorization request for the client, resource and scopes
+// is remembered. It is a digest, so that the cookie stays small.
+func approvalKey(clientID, resource string, scopes []string) string {
+	scopes = slices.Sorted(slices.Values(scopes))
+	data, _ := json.Marshal([]any{clientID, resource, slices.Compact(scopes)})
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// isApproved returns true if the user approved the request with the given key before and the approval is still valid.
+func (c *consentPage) isApproved(r *http.Request, key string) bool {
+	approvals, _ := c.approvals(r)
+	expiry, ok := approvals[key]
+	return ok && time.Now().Unix() < expiry
+}
+
+// remember sets a cookie which contains the approval of the request with the given key in addition to all previous
+// approvals.
+func (c *consentPage) remember(w http.ResponseWriter, r *http.Request, key string) {
+	if c.rememberFor <= 0 {
+		return
+	}
+
+	approvals, _ := c.approvals(r)
+	now := time.Now().Unix()
+	maps.DeleteFunc(approvals, func(_ string, expiry int64) bool { return expiry <= now })
+	approvals[key] = time.Now().Add(c.rememberFor).Unix()
+
+	// the approvals that expire first are dropped if there are too many
+	for len(approvals) > maxRememberedConsents {
+		keys := slices.SortedFunc(maps.Keys(approvals), func(a, b string) int { return cmp.Compare(approvals[a], approvals[b]) })
+		delete(approvals, keys[0])
+	}
+
+	data, _ := json.Marshal(approvals)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	http.SetCookie(w, &http.Cookie{
+		Name:     consentCookieName,
+		Value:    payload + "." + c.sign(payload),
+		Path:     c.cookiePath,
+		MaxAge:   int(c.rememberFor.Seconds()),
+		Secure:   c.secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (c *consentPage) approvals(r *http.Request) (map[string]int64, error) {
+	approvals := map[string]int64{}
+	if cookie, err := r.Cookie(consentCookieName); err != nil {
+		return approvals, err
+	} else if payload, signature, ok := strings.Cut(cookie.Value, "."); !ok || !hmac.Equal([]byte(signature), []byte(c.sign(payload))) {
+		return approvals, errInvalidConsentCookie
+	} else if data, err := base64.RawURLEncoding.DecodeString(payload); err != nil {
+		return approvals, errInvalidConsentCookie
+	} else if err := json.Unmarshal(data, &approvals); err != nil {
+		return map[string]int64{}, errInvalidConsentCookie
+	} else {
+		return approvals, nil
+	}
+}
+
+func (c *consentPage) sign(payload string) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// csrfToken returns a new token for the consent form, which is also set as a cookie. A decision is only accepted if the
+// form contains the same token as the cookie, so that other sites can not submit it on behalf of the user.
+func (c *consentPage) csrfToken(w http.ResponseWriter) string {
+	token := genRandom()
+	http.SetCookie(w, &http.Cookie{
+		Name:     consentCSRFCookieName,
+		Value:    token,
+		Path:     c.cookiePath,
+		Secure:   c.secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token
+}
+
+// checkCSRFToken returns true if the posted consent form contains the token of the CSRF cookie. The cookie is deleted,
+// so that every token can only be used once.
+func (c *consentPage) checkCSRFToken(w http.ResponseWriter, r *http.Request) bool {
+	cookie, err := r.Cookie(consentCSRFCookieName)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: consentCSRFCookieName, Path: c.cookiePath, MaxAge: -1})
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(r.PostFormValue("csrf_token"))) == 1
+}
+
+// resource returns the protected resource that the authorization request is for. Requests without a resource are for
+// the only protected resource or, if there are several, for the given default resource. False is returned if the
+// requested resource is not protected by the gateway.
+func (c *consentPage) resource(q url.Values, defaultResource string) (string, bool) {
+	resource := strings.TrimSuffix(q.Get("resource"), "/")
+	if resource == "" && len(c.resources) == 1 {
+		return c.resources[0], true
+	} else if resource == "" {
+		return defaultResource, true
+	}
+
+	for _, protected := range c.resources {
+		if resource == strings.TrimSuffix(protected, "/") {
+			return protected, true
+		} else if strings.HasSuffix(protected, "/") && strings.HasPrefix(resource, protected) {
+			// proxies whose path ends with a slash also serve all nested paths
+			return resource, true
+		}
+	}
+
+	return "", false
+}
+
+// handle shows the consent page or handles the decision that was posted from it. It returns true if the authorization
+// request was approved and may continue to the authorization server, otherwise a response was written.
+func (c *consentPage) handle(w http.ResponseWriter, r *http.Request, registry *ClientRegistry, q url.Values, server string) bool {
+	clientID := q.Get("client_id")
+	resource, ok := c.resource(q, server)
+	if !ok {
+		writeAuthorizationError(w, r, invalidAuthorizationRequest("The resource %v is not protected by this server.", q.Get("resource")))
+		return false
+	}
+
+	scopes := strings.Fields(q.Get("scope"))
+	key := approvalKey(clientID, resource, scopes)
+
+	var registration *Registration
+	if registry != nil {
+		registration, _ = registry.registration(r.Context(), clientID)
+	}
+
+	if r.Method == http.MethodPost {
+		if !c.checkCSRFToken(w, r) {
+			writeAuthorizationError(w, r, invalidAuthorizationRequest("The consent form has expired. Please try again."))
+			return false
+		} else if r.PostFormValue("decision") == "approve" {
+			c.remember(w, r, key)
+			return true
+		}
+
+		log.Get(r.Context()).Info("authorization request denied by user", "client_id", clientID)
+		// the redirect URI can only be trusted if it was checked against a registered client
+		if redirectURI, err := url.Parse(q.Get("redirect_uri")); err == nil && registration != nil {
+			rq := redirectURI.Query()
+			rq.Set("error", "access_denied")
+			rq.Set("state", q.Get("state"))
+			redirectURI.RawQuery = rq.Encode()
+			http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+		} else {
+			htmlresponse.WriteError(w, http.StatusForbidden, "Access denied",
+				"You denied the authorization request.", "You can close this window.")
+		}
+		return false
+	} else if c.isApproved(r, key) {
+		return true
+	}
+
+	data := htmlresponse.ConsentData{
+		ClientID:   clientID,
+		ClientName: clientID,
+		Registered: registration != nil,
+		Server:     resource,
+		Scopes:     scopes,
+		Action:     r.URL.RequestURI(),
+		CSRFToken:  c.csrfToken(w),
+	}
+	if registration != nil {
+		data.LogoURI = registration.Client.LogoURI
+		if registration.Client.ClientName != "" {
+			data.ClientName = registration.Client.ClientName
+		}
+	}
+
+	htmlresponse.WriteConsent(w, data)
+	return false
+}
@@ -0,0 +1,94 @@
+package oauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyprmcp/mcp-gateway/config"
+)
+
+func TestConsentCookie(t *testing.T) {
+	rememberFor := time.Hour
+	page := newConsentPage(config.Consent{Enabled: true, RememberFor: &rememberFor, Secret: "secret"}, "/", true, nil)
+	other := newConsentPage(config.Consent{Enabled: true, RememberFor: &rememberFor, Secret: "other"}, "/", true, nil)
+
+	key := approvalKey("client", "https://gateway.example.com/mcp", []string{"openid", "email"})
+	rec := httptest.NewRecorder()
+	page.remember(rec, httptest.NewRequest(http.MethodGet, "/", nil), key)
+	cookie := rec.Result().Cookies()[0]
+
+	payload, signature, _ := strings.Cut(cookie.Value, ".")
+	tamperedPayload := &http.Cookie{Name: consentCookieName, Value: "e30." + signature}
+	tamperedSignature := &http.Cookie{Name: consentCookieName, Value: payload + "." + page.sign("e30")}
+
+	tests := []struct {
+		name   string
+		page   *consentPage
+		cookie *http.Cookie
+		key    string
+		want   bool
+	}{
+		{"approved", page, cookie, key, true},
+		{"scopes in other order", page, cookie, approvalKey("client", "https://gateway.example.com/mcp", []string{"email", "openid"}), true},
+		{"other client", page, cookie, approvalKey("other", "https://gateway.example.com/mcp", []string{"openid", "email"}), false},
+		{"other resource", page, cookie, approvalKey("client", "https://gateway.example.com/other", []string{"openid", "email"}), false},
+		{"additional scope", page, cookie, approvalKey("client", "https://gateway.example.com/mcp", []string{"openid", "email", "admin"}), false},
+		{"no cookie", page, nil, key, false},
+		{"other secret", other, cookie, key, false},
+		{"tampered payload", page, tamperedPayload, key, false},
+		{"tampered signature", page, tamperedSignature, key, false},
+		{"unsigned", page, &http.Cookie{Name: consentCookieName, Value: "e30"}, key, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.cookie != nil {
+				r.AddCookie(tt.cookie)
+			}
+
+			if got := tt.page.isApproved(r, tt.key); got != tt.want {
+				t.Errorf("isApproved() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConsentResource(t *testing.T) {
+	page := newConsentPage(config.Consent{Enabled: true}, "/", true,
+		[]string{"https://gateway.example.com/mcp", "https://gateway.example.com/tools/"})
+	single := newConsentPage(config.Consent{Enabled: true}, "/", true, []string{"https://gateway.example.com/mcp"})
+
+	tests := []struct {
+		name     string
+		page     *consentPage
+		resource string
+		want     string
+		wantOK   bool
+	}{
+		{"protected", page, "https://gateway.example.com/mcp", "https://gateway.example.com/mcp", true},
+		{"trailing slash", page, "https://gateway.example.com/mcp/", "https://gateway.example.com/mcp", true},
+		{"nested", page, "https://gateway.example.com/tools/a", "https://gateway.example.com/tools/a", true},
+		{"default", page, "", "https://gateway.example.com", true},
+		{"single resource", single, "", "https://gateway.example.com/mcp", true},
+		{"other host", page, "https://evil.example.com/mcp", "", false},
+		{"other path", page, "https://gateway.example.com/mcp2", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := url.Values{}
+			if tt.resource != "" {
+				q.Set("resource", tt.resource)
+			}
+
+			if got, ok := tt.page.resource(q, "https://gateway.example.com"); got != tt.want || ok != tt.wantOK {
+				t.Errorf("resource() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -44,3 +44,15 @@ func GetOriginalURL(ctx context.Context) *url.URL {
 
 	return nil
 }
+
+// tokenClientID returns the ID of the client that the token was issued to.
+func tokenClientID(token jwt.Token) string {
+	var azp string
+	if err := token.Get("azp", &azp); err == nil && azp != "" {
+		return azp
+	} else if aud, ok := token.Audience(); ok && len(aud) == 1 {
+		return aud[0]
+	} else {
+		return ""
+	}
+}
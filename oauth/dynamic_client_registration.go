@@ -3,104 +3,153 @@ package oauth
 import (
 	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"net/url"
 	"slices"
 	"strings"
 
-	"github.com/dexidp/dex/api/v2"
-	"github.com/hyprmcp/mcp-gateway/config"
 	"github.com/hyprmcp/mcp-gateway/log"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 const DynamicClientRegistrationPath = "/oauth/register"
+const ClientConfigurationPath = "/oauth/clients"
 
 type ClientInformation struct {
 	ClientID              string   `json:"client_id"`
 	ClientSecret          string   `json:"client_secret,omitempty"`
 	ClientSecretExpiresAt int64    `json:"client_secret_expires_at,omitempty"`
+	ClientIDIssuedAt      int64    `json:"client_id_issued_at,omitempty"`
 	ClientName            string   `json:"client_name,omitempty"`
 	RedirectURIs          []string `json:"redirect_uris"`
 	LogoURI               string   `json:"logo_uri,omitempty"`
 	Scope                 string   `json:"scope,omitempty"`
+	GrantTypes            []string `json:"grant_types,omitempty"`
+	ResponseTypes         []string `json:"response_types,omitempty"`
+	// TokenEndpointAuthMethod is set to none for public clients.
+	TokenEndpointAuthMethod string `json:"token_endpoint_auth_method,omitempty"`
+	// RegistrationAccessToken and RegistrationClientURI are used to manage the client as defined in RFC 7592.
+	RegistrationAccessToken string `json:"registration_access_token,omitempty"`
+	RegistrationClientURI   string `json:"registration_client_uri,omitempty"`
 }
 
-func NewDynamicClientRegistrationHandler(config *config.Config, meta map[string]any) (http.Handler, error) {
-	clientTLSConfig, err := config.DexGRPCClient.ClientTLSConfig()
-	if err != nil {
-		return nil, err
-	}
-
-	var creds credentials.TransportCredentials
-
-	if clientTLSConfig != nil {
-		creds = credentials.NewTLS(clientTLSConfig)
-	} else {
-		creds = insecure.NewCredentials()
-	}
-
-	grpcClient, err := grpc.NewClient(
-		config.DexGRPCClient.Addr,
-		grpc.WithTransportCredentials(creds),
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	dexClient := api.NewDexClient(grpcClient)
-
+// NewDynamicClientRegistrationHandler creates clients as defined in RFC 7591. The response contains a registration
+// access token that can be used to manage the client at its URI below clientsURL.
+func NewDynamicClientRegistrationHandler(registry *ClientRegistry, metadata *MetadataCache, clientsURL *url.URL) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var body ClientInformation
 		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeOAuthError(w, http.StatusBadRequest, "invalid_client_metadata", "invalid request body")
 			return
 		}
 
 		log.Get(r.Context()).Info("Received dynamic client registration request", "body", body)
 
-		client := api.Client{
-			Id:           genRandom(),
-			Name:         body.ClientName,
-			LogoUrl:      body.LogoURI,
-			RedirectUris: body.RedirectURIs,
-			Public:       true,
-		}
-
-		if !config.Authorization.GetDynamicClientRegistration().PublicClient {
-			client.Secret = genRandom()
-		}
-
-		clientResponse, err := dexClient.CreateClient(r.Context(), &api.CreateClientReq{Client: &client})
-		if err != nil {
+		var regErr *RegistrationError
+		resp, token, err := registry.Register(r.Context(), body)
+		if errors.As(err, &regErr) {
+			log.Get(r.Context()).Info("Rejected dynamic client registration request", "error", regErr.Error())
+			writeOAuthError(w, http.StatusBadRequest, regErr.Code, regErr.Description)
+			return
+		} else if err != nil {
 			log.Get(r.Context()).Error(err, "failed to create client")
 			http.Error(w, "Failed to create client", http.StatusInternalServerError)
 			return
 		}
 
-		w.WriteHeader(http.StatusCreated)
-		w.Header().Set("Content-Type", "application/json")
+		resp.RegistrationAccessToken = token
+		writeClientInformation(w, r, http.StatusCreated, resp, metadata.Get(), clientsURL)
+
+		log.Get(r.Context()).Info("Client created successfully", "client_id", resp.ClientID)
+	})
+}
 
-		resp := ClientInformation{
-			ClientID:     clientResponse.Client.Id,
-			ClientSecret: clientResponse.Client.Secret,
-			ClientName:   clientResponse.Client.Name,
-			RedirectURIs: clientResponse.Client.RedirectUris,
-			LogoURI:      clientResponse.Client.LogoUrl,
+// NewClientConfigurationHandler implements the client configuration endpoint of RFC 7592, which allows clients to
+// read, update and delete their registration with the registration access token.
+func NewClientConfigurationHandler(registry *ClientRegistry, metadata *MetadataCache, clientsURL *url.URL) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientID := r.PathValue("clientID")
+		rawToken, ok := BearerToken(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
 		}
 
-		if scopesSupported := getSupportedScopes(meta); len(scopesSupported) > 0 {
-			resp.Scope = strings.Join(scopesSupported, " ")
+		registration, err := registry.Authenticate(r.Context(), clientID, rawToken)
+		if errors.Is(err, ErrClientNotFound) || errors.Is(err, errInvalidRegistrationToken) {
+			// RFC 7592 does not distinguish between unknown clients and invalid tokens
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		} else if err != nil {
+			log.Get(r.Context()).Error(err, "failed to read client registration", "client_id", clientID)
+			http.Error(w, "Failed to read client registration", http.StatusInternalServerError)
+			return
 		}
 
-		err = json.NewEncoder(w).Encode(resp)
-		if err != nil {
-			log.Get(r.Context()).Error(err, "Failed to encode response")
+		switch r.Method {
+		case http.MethodGet:
+			writeClientInformation(w, r, http.StatusOK, &registration.Client, metadata.Get(), clientsURL)
+		case http.MethodPut:
+			var body ClientInformation
+			var regErr *RegistrationError
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				writeOAuthError(w, http.StatusBadRequest, "invalid_client_metadata", "invalid request body")
+			} else if body.ClientID != clientID {
+				writeOAuthError(w, http.StatusBadRequest, "invalid_client_metadata", "client_id does not match")
+			} else if body.ClientSecret != "" && body.ClientSecret != registration.Client.ClientSecret {
+				writeOAuthError(w, http.StatusBadRequest, "invalid_client_metadata", "client_secret does not match")
+			} else if client, err := registry.Update(r.Context(), body); errors.As(err, &regErr) {
+				writeOAuthError(w, http.StatusBadRequest, regErr.Code, regErr.Description)
+			} else if err != nil {
+				log.Get(r.Context()).Error(err, "failed to update client", "client_id", clientID)
+				http.Error(w, "Failed to update client", http.StatusInternalServerError)
+			} else {
+				writeClientInformation(w, r, http.StatusOK, client, metadata.Get(), clientsURL)
+				log.Get(r.Context()).Info("Client updated successfully", "client_id", clientID)
+			}
+		case http.MethodDelete:
+			if err := registry.Delete(r.Context(), clientID); err != nil {
+				log.Get(r.Context()).Error(err, "failed to delete client", "client_id", clientID)
+				http.Error(w, "Failed to delete client", http.StatusInternalServerError)
+			} else {
+				w.WriteHeader(http.StatusNoContent)
+				log.Get(r.Context()).Info("Client deleted successfully", "client_id", clientID)
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT, DELETE")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 		}
+	})
+}
+
+func writeClientInformation(
+	w http.ResponseWriter,
+	r *http.Request,
+	status int,
+	client *ClientInformation,
+	meta map[string]any,
+	clientsURL *url.URL,
+) {
+	resp := *client
+	resp.RegistrationClientURI = clientsURL.JoinPath(client.ClientID).String()
+
+	if resp.ClientSecret == "" {
+		resp.TokenEndpointAuthMethod = "none"
+	}
 
-		log.Get(r.Context()).Info("Client created successfully", "client_id", clientResponse.Client.Id)
-	}), nil
+	if scopesSupported := getSupportedScopes(meta); len(scopesSupported) > 0 {
+		resp.Scope = strings.Join(scopesSupported, " ")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Get(r.Context()).Error(err, "Failed to encode response")
+	}
 }
 
 func genRandom() string {
@@ -0,0 +1,78 @@
+package oauth
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/hyprmcp/mcp-gateway/log"
+)
+
+// metadataRetryInterval is the time after which a failed refresh of the metadata is retried, if it is shorter than the
+// TTL.
+const metadataRetryInterval = 30 * time.Second
+
+// MetadataCache caches the metadata of an authorization server and refreshes it in the background. If a refresh fails,
+// the previous metadata is used until a refresh succeeds, so that a short outage of the authorization server does not
+// affect clients that discover it through the gateway.
+type MetadataCache struct {
+	server string
+	ttl    time.Duration
+
+	mu          sync.RWMutex
+	metadata    map[string]any
+	lastRefresh time.Time
+}
+
+// NewMetadataCache fetches the metadata of the given authorization server and returns a cache for it. Call Run to
+// refresh the metadata in the background.
+func NewMetadataCache(server string, ttl time.Duration) (*MetadataCache, error) {
+	metadata, err := GetMedatata(server)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MetadataCache{server: server, ttl: ttl, metadata: metadata, lastRefresh: time.Now()}, nil
+}
+
+// Get returns a copy of the cached metadata, which may be modified by the caller.
+func (c *MetadataCache) Get() map[string]any {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return maps.Clone(c.metadata)
+}
+
+// LastRefresh returns the time of the last successful refresh.
+func (c *MetadataCache) LastRefresh() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastRefresh
+}
+
+// Run refreshes the metadata whenever the TTL has passed until ctx is canceled. onRefresh is called with a copy of the
+// metadata after every successful refresh.
+func (c *MetadataCache) Run(ctx context.Context, onRefresh func(metadata map[string]any)) {
+	timer := time.NewTimer(c.ttl)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			if metadata, err := GetMedatata(c.server); err != nil {
+				log.Get(ctx).Error(err, "failed to refresh authorization server metadata, using cached metadata",
+					"server", c.server, "lastRefresh", c.LastRefresh())
+				timer.Reset(min(c.ttl, metadataRetryInterval))
+			} else {
+				c.mu.Lock()
+				c.metadata = metadata
+				c.lastRefresh = time.Now()
+				c.mu.Unlock()
+				onRefresh(maps.Clone(metadata))
+				timer.Reset(c.ttl)
+			}
+		}
+	}
+}
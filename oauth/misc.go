@@ -6,6 +6,9 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"strings"
+
+	"github.com/hyprmcp/mcp-gateway/config"
+	"github.com/hyprmcp/mcp-gateway/log"
 )
 
 func RewriteSetOriginalURL(r *httputil.ProxyRequest) {
@@ -16,8 +19,7 @@ func (mgr *Manager) UpdateWWWAuthenticateHeader(resp *http.Response) error {
 	if resp.StatusCode == http.StatusUnauthorized {
 		realRequestURL := GetOriginalURL(resp.Request.Context())
 		upstreamMetaURL, _ := url.Parse(resp.Request.URL.String())
-		upstreamMetaURL.Path, _ = url.JoinPath(ProtectedResourcePath, upstreamMetaURL.Path)
-		upstreamMetaURLStr := upstreamMetaURL.String()
+		upstreamMetaURLStr := wellKnownURL(upstreamMetaURL, ProtectedResourcePath).String()
 
 		if value := resp.Header.Get("WWW-Authenticate"); value != "" {
 			valueParts := strings.Split(value, " ")
@@ -36,8 +38,48 @@ func (mgr *Manager) UpdateWWWAuthenticateHeader(resp *http.Response) error {
 		// We also store the metadata URL if it was not found in the WWW-Authenticate header.
 		// This is necessary to ensure that we don't return the wrong metadata later when the
 		// client calls our metadata endpoint because of some heuristic.
-		upstreamMetadataURLs.Store(strings.Trim(realRequestURL.Path, `/`), upstreamMetaURLStr)
+		key := upstreamMetadataKey(mgr.config, realRequestURL.Path)
+		if err := mgr.upstreamMetadata.Set(resp.Request.Context(), key, upstreamMetaURLStr); err != nil {
+			log.Get(resp.Request.Context()).Error(err, "failed to store upstream metadata URL", "resource", key)
+		}
 	}
 
 	return nil
 }
+
+// upstreamMetadataKey returns the key of the resource at the given path in the UpstreamMetadataStore.
+func upstreamMetadataKey(cfg *config.Config, path string) string {
+	resourceURL, _ := url.Parse(cfg.Host.String())
+	resourceURL.Path = "/" + strings.Trim(path, "/")
+	resourceURL.RawPath = ""
+	return resourceURL.String()
+}
+
+// wellKnownURL returns the URL of the given well-known path for the resource or issuer at u. The well-known path is
+// inserted between the host and the path of u as defined in RFC 8414, Section 3.1 and RFC 9728, Section 3.1.
+func wellKnownURL(u *url.URL, wellKnownPath string) *url.URL {
+	wellKnownPath = strings.TrimSuffix(wellKnownPath, "/")
+	result := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: wellKnownPath}
+	if u.Path != "" && u.Path != "/" {
+		result.Path = wellKnownPath + "/" + strings.TrimPrefix(u.Path, "/")
+		if u.RawPath != "" {
+			result.RawPath = wellKnownPath + "/" + strings.TrimPrefix(u.RawPath, "/")
+		}
+	}
+	return result
+}
+
+// BearerToken returns the token of the Authorization header of r if it uses the Bearer scheme.
+func BearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(r.Header.Get("Authorization")), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 	"time"
 
@@ -13,85 +14,290 @@ import (
 	"github.com/hyprmcp/mcp-gateway/config"
 	"github.com/hyprmcp/mcp-gateway/htmlresponse"
 	"github.com/hyprmcp/mcp-gateway/log"
-	"github.com/lestrrat-go/httprc/v3"
-	"github.com/lestrrat-go/httprc/v3/errsink"
-	"github.com/lestrrat-go/httprc/v3/tracesink"
-	"github.com/lestrrat-go/jwx/v3/jwk"
 	"github.com/lestrrat-go/jwx/v3/jwt"
 )
 
 type Manager struct {
-	jwkSet         jwk.Set
-	config         *config.Config
-	authServerMeta map[string]any
-}
-
-func NewManager(ctx context.Context, config *config.Config) (*Manager, error) {
-	log := log.Get(ctx)
-
-	timeoutCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
-
-	if cache, err := jwk.NewCache(ctx, httprc.NewClient(
-		httprc.WithTraceSink(tracesink.Func(func(ctx context.Context, s string) { log.V(1).Info(s) })),
-		httprc.WithErrorSink(errsink.NewFunc(func(ctx context.Context, err error) { log.V(1).Error(err, "httprc.NewClient error") })),
-	)); err != nil {
-		return nil, fmt.Errorf("jwk cache creation error: %w", err)
-	} else if meta, err := GetMedatata(config.Authorization.Server); err != nil {
-		return nil, fmt.Errorf("authorization server metadata error: %w", err)
-	} else if jwksURI, ok := meta["jwks_uri"].(string); !ok {
-		return nil, errors.New("no jwks_uri")
-	} else if err := cache.Register(
-		timeoutCtx,
-		jwksURI,
-		jwk.WithMinInterval(10*time.Second),
-		jwk.WithMaxInterval(5*time.Minute),
-	); err != nil {
-		return nil, fmt.Errorf("jwks registration error: %w", err)
-	} else if _, err := cache.Refresh(timeoutCtx, jwksURI); err != nil {
-		return nil, fmt.Errorf("jwks refresh error: %w", err)
-	} else if s, err := cache.CachedSet(jwksURI); err != nil {
-		return nil, fmt.Errorf("jwks cache set error: %w", err)
-	} else {
-		return &Manager{jwkSet: s, config: config, authServerMeta: meta}, nil
+	config *config.Config
+	// authServers contains the state of all authorization servers that are used by this Manager, in the same order
+	// as they are returned by authorizationScopes.
+	authServers []*authServer
+	// upstreamMetadata is shared by all Managers of a router.
+	upstreamMetadata UpstreamMetadataStore
+}
+
+// JWKSStatus describes the state of a JWKS that is cached by the Manager.
+type JWKSStatus struct {
+	URL         string    `json:"url"`
+	KeyIDs      []string  `json:"keyIds"`
+	LastRefresh time.Time `json:"lastRefresh,omitzero"`
+	NextRefresh time.Time `json:"nextRefresh,omitzero"`
+}
+
+// authorizationScope is an authorization server configuration together with the path prefix of the gateway's
+// authorization server endpoints for it. The global configuration has an empty prefix, proxies with their own
+// authorization configuration use their path as prefix.
+type authorizationScope struct {
+	prefix        string
+	authorization *config.Authorization
+	dexGRPCClient *config.DexGRPCClient
+}
+
+func authorizationScopes(cfg *config.Config) []authorizationScope {
+	auth, dex := cfg.AuthorizationFor(nil)
+	scopes := []authorizationScope{{prefix: "", authorization: auth, dexGRPCClient: dex}}
+	for _, p := range cfg.Proxy {
+		if p.Authorization != nil || p.DexGRPCClient != nil {
+			auth, dex := cfg.AuthorizationFor(&p)
+			scopes = append(scopes, authorizationScope{prefix: p.Path, authorization: auth, dexGRPCClient: dex})
+		}
 	}
+	return scopes
 }
 
-func (mgr *Manager) Register(mux *http.ServeMux) error {
-	mux.Handle(ProtectedResourcePath, NewProtectedResourceHandler(mgr.config))
+// protectedResources returns the URLs of the proxies that require authentication with the authorization server of the
+// scope with the given prefix.
+func protectedResources(cfg *config.Config, prefix string) []string {
+	var resources []string
+	for _, p := range cfg.Proxy {
+		if p.Authentication.Enabled && scopeFor(cfg, p.Path).prefix == prefix {
+			resourceURL, _ := url.Parse(cfg.Host.String())
+			resources = append(resources, resourceURL.JoinPath(p.Path).String())
+		}
+	}
+	return resources
+}
+
+// scopeFor returns the authorization scope of the proxy that serves the given path. Proxies whose path ends with a
+// slash also serve all nested paths, in which case the proxy with the longest matching path is used.
+func scopeFor(cfg *config.Config, proxyPath string) authorizationScope {
+	proxyPath = "/" + strings.Trim(proxyPath, "/")
+	var match *config.Proxy
+	for i, p := range cfg.Proxy {
+		if p.Authorization == nil && p.DexGRPCClient == nil {
+			continue
+		} else if match != nil && len(match.Path) >= len(p.Path) {
+			continue
+		} else if "/"+strings.Trim(p.Path, "/") == proxyPath ||
+			(strings.HasSuffix(p.Path, "/") && strings.HasPrefix(proxyPath+"/", p.Path)) {
+			match = &cfg.Proxy[i]
+		}
+	}
 
-	if mgr.config.Authorization.ServerMetadataProxyEnabled {
-		mux.Handle(AuthorizationServerMetadataPath, NewAuthorizationServerMetadataHandler(mgr.config))
+	auth, dex := cfg.AuthorizationFor(match)
+	if match != nil {
+		return authorizationScope{prefix: match.Path, authorization: auth, dexGRPCClient: dex}
 	}
+	return authorizationScope{prefix: "", authorization: auth, dexGRPCClient: dex}
+}
+
+// NewManager creates a new Manager for the given configuration.
+//
+// The state (metadata, JWKS cache and Dex gRPC client) of authorization servers that are used by one of the reusable
+// Managers is reused if their configuration did not change. All other authorization servers are created from scratch
+// and are stopped when ctx is canceled or when Release is called.
+//
+// The upstreamMetadata store is used to remember the protected resource metadata URLs of upstream servers.
+func NewManager(
+	ctx context.Context,
+	config *config.Config,
+	upstreamMetadata UpstreamMetadataStore,
+	reusable ...*Manager,
+) (*Manager, error) {
+	mgr := &Manager{config: config, upstreamMetadata: upstreamMetadata}
 
-	if mgr.config.Authorization.GetDynamicClientRegistration().Enabled {
-		if handler, err := NewDynamicClientRegistrationHandler(mgr.config, mgr.authServerMeta); err != nil {
-			return err
+	for _, scope := range authorizationScopes(config) {
+		var as *authServer
+		isReusable := func(as *authServer) bool { return as.reusableFor(scope) }
+		if idx := slices.IndexFunc(mgr.authServers, isReusable); idx >= 0 {
+			as = mgr.authServers[idx]
 		} else {
-			rateLimiter := httprate.LimitByRealIP(3, 10*time.Minute)
-			mux.Handle(DynamicClientRegistrationPath, rateLimiter(handler))
+			for _, other := range reusable {
+				if other == nil {
+					continue
+				} else if idx := slices.IndexFunc(other.authServers, isReusable); idx >= 0 {
+					as = other.authServers[idx]
+					log.Get(ctx).Info("Reusing authorization server state", "server", as.server)
+					break
+				}
+			}
+		}
+
+		if as == nil {
+			if newAS, err := newAuthServer(ctx, scope); err != nil {
+				mgr.Release(reusable...)
+				return nil, fmt.Errorf("authorization server %v: %w", scope.authorization.Server, err)
+			} else {
+				as = newAS
+			}
+		}
+
+		mgr.authServers = append(mgr.authServers, as)
+	}
+
+	return mgr, nil
+}
+
+// Release stops all authorization servers of this Manager that are not used by any of the next Managers.
+func (mgr *Manager) Release(next ...*Manager) {
+	for _, as := range mgr.authServers {
+		if !slices.ContainsFunc(next, func(other *Manager) bool { return other != nil && slices.Contains(other.authServers, as) }) {
+			as.stop()
+		}
+	}
+}
+
+func (mgr *Manager) authServerFor(proxyPath string) *authServer {
+	prefix := scopeFor(mgr.config, proxyPath).prefix
+	for i, s := range authorizationScopes(mgr.config) {
+		if s.prefix == prefix {
+			return mgr.authServers[i]
 		}
 	}
+	return mgr.authServers[0]
+}
 
-	if mgr.config.Authorization.AuthorizationProxyEnabled {
-		if handler, err := NewAuthorizationHandler(mgr.config, mgr.authServerMeta); err != nil {
-			return err
+// JWKSStatus returns the key IDs and refresh times of all JWKS used to verify access tokens.
+func (mgr *Manager) JWKSStatus(ctx context.Context) []JWKSStatus {
+	var result []JWKSStatus
+	for i, as := range mgr.authServers {
+		if slices.Index(mgr.authServers, as) == i {
+			result = append(result, as.jwksStatus(ctx))
+		}
+	}
+	return result
+}
+
+// RefreshJWKS forces a refresh of all JWKS used to verify access tokens.
+func (mgr *Manager) RefreshJWKS(ctx context.Context) error {
+	var errs []error
+	for i, as := range mgr.authServers {
+		if slices.Index(mgr.authServers, as) == i {
+			errs = append(errs, as.refreshJWKS(ctx))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ClientStatus describes a dynamically registered client.
+type ClientStatus struct {
+	Server       string   `json:"server"`
+	ClientID     string   `json:"clientId"`
+	ClientName   string   `json:"clientName,omitempty"`
+	RedirectURIs []string `json:"redirectUris"`
+	// MetadataDocumentURL is set if the client was registered for a client ID metadata document.
+	MetadataDocumentURL string    `json:"metadataDocumentUrl,omitempty"`
+	CreatedAt           time.Time `json:"createdAt"`
+	LastUsedAt          time.Time `json:"lastUsedAt"`
+}
+
+func newClientStatus(server string, registration Registration) ClientStatus {
+	return ClientStatus{
+		Server:              server,
+		ClientID:            registration.Client.ClientID,
+		ClientName:          registration.Client.ClientName,
+		RedirectURIs:        registration.Client.RedirectURIs,
+		MetadataDocumentURL: registration.MetadataDocumentURL,
+		CreatedAt:           registration.CreatedAt,
+		LastUsedAt:          registration.LastUsedAt,
+	}
+}
+
+// Clients returns all clients that were registered with dynamic client registration.
+func (mgr *Manager) Clients(ctx context.Context) ([]ClientStatus, error) {
+	var result []ClientStatus
+	for i, as := range mgr.authServers {
+		if as.registry == nil || slices.Index(mgr.authServers, as) != i {
+			continue
+		} else if registrations, err := as.registry.List(ctx); err != nil {
+			return nil, err
 		} else {
-			mux.Handle(AuthorizationPath, handler)
+			for _, registration := range registrations {
+				result = append(result, newClientStatus(as.server, registration))
+			}
+		}
+	}
+	return result, nil
+}
+
+// PruneClients deletes all clients that have not been used for longer than unusedFor and returns them. If unusedFor is
+// zero, the unusedClientExpiry of each authorization server is used instead.
+func (mgr *Manager) PruneClients(ctx context.Context, unusedFor time.Duration) ([]ClientStatus, error) {
+	var result []ClientStatus
+	var errs []error
+	for i, as := range mgr.authServers {
+		if as.registry == nil || slices.Index(mgr.authServers, as) != i {
+			continue
+		}
+
+		pruned, err := as.registry.Prune(ctx, unusedFor)
+		errs = append(errs, err)
+		for _, registration := range pruned {
+			result = append(result, newClientStatus(as.server, registration))
+		}
+	}
+	return result, errors.Join(errs...)
+}
+
+func (mgr *Manager) Register(mux *http.ServeMux) error {
+	protectedResourceHandler := NewProtectedResourceHandler(mgr.config, mgr.upstreamMetadata)
+	mux.Handle(ProtectedResourcePath, protectedResourceHandler)
+	mux.Handle(strings.TrimSuffix(ProtectedResourcePath, "/"), protectedResourceHandler)
+
+	for i, scope := range authorizationScopes(mgr.config) {
+		as := mgr.authServers[i]
+
+		if scope.authorization.ServerMetadataProxyEnabled {
+			mux.Handle(AuthorizationServerMetadataPath+scope.prefix,
+				NewAuthorizationServerMetadataHandler(mgr.config, scope.authorization, scope.prefix, as.metadata))
+		}
+
+		if scope.authorization.GetDynamicClientRegistration().Enabled {
+			clientsPath := ClientConfigurationPath + strings.TrimSuffix(scope.prefix, "/")
+			clientsURL, _ := url.Parse(mgr.config.Host.String())
+			clientsURL.Path = clientsPath
+			rateLimiter := httprate.LimitByRealIP(3, 10*time.Minute)
+			mux.Handle(DynamicClientRegistrationPath+scope.prefix,
+				rateLimiter(NewDynamicClientRegistrationHandler(as.registry, as.metadata, clientsURL)))
+			mux.Handle(clientsPath+"/{clientID}", NewClientConfigurationHandler(as.registry, as.metadata, clientsURL))
+		}
+
+		// registration backends that issue their own client IDs and client ID metadata documents need the gateway's
+		// token endpoint to map client IDs
+		if as.registry.mapsClientIDs() {
+			if handler, err := NewTokenHandler(as.registry, as.metadata); err != nil {
+				return err
+			} else {
+				mux.Handle(TokenPath+scope.prefix, handler)
+			}
+		}
+
+		if scope.authorization.AuthorizationProxyEnabled {
+			if handler, err := NewAuthorizationHandler(mgr.config, scope.authorization, scope.prefix, as.metadata, as.registry); err != nil {
+				return err
+			} else {
+				mux.Handle(AuthorizationPath+scope.prefix, handler)
+			}
 		}
 	}
 
 	return nil
 }
 
-func (mgr *Manager) Handler(next http.Handler) http.Handler {
+// Handler verifies the access token of each request to the given proxy with the keys of the proxy's authorization
+// server.
+func (mgr *Manager) Handler(proxyConfig *config.Proxy, next http.Handler) http.Handler {
 	htmlHandler := htmlresponse.NewHandler(mgr.config, true)
+	as := mgr.authServerFor(proxyConfig.Path)
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		rawToken :=
 			strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(r.Header.Get("Authorization")), "Bearer"))
-		if token, err := jwt.ParseString(rawToken, jwt.WithKeySet(mgr.jwkSet)); err != nil {
+		if token, err := jwt.ParseString(rawToken, jwt.WithKeySet(as.keySet())); err != nil {
 			htmlHandler.Handler(mgr.unauthorizedHandler()).ServeHTTP(w, r)
 		} else {
+			if as.registry != nil {
+				as.registry.Touch(r.Context(), tokenClientID(token))
+			}
 			next.ServeHTTP(w, r.WithContext(TokenContext(r.Context(), token, rawToken)))
 		}
 	})
@@ -104,9 +310,10 @@ func (mgr *Manager) unauthorizedHandler() http.HandlerFunc {
 	}
 }
 
+// getMetadataURL returns the URL of the protected resource metadata for the resource at the path of u.
 func (mgr *Manager) getMetadataURL(u *url.URL) *url.URL {
-	metadataURL, _ := url.Parse(mgr.config.Host.String())
-	metadataURL.Path = ProtectedResourcePath
-	metadataURL = metadataURL.JoinPath(u.Path)
-	return metadataURL
+	resourceURL, _ := url.Parse(mgr.config.Host.String())
+	resourceURL.Path = u.Path
+	resourceURL.RawPath = u.RawPath
+	return wellKnownURL(resourceURL, ProtectedResourcePath)
 }
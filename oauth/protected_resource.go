@@ -3,12 +3,10 @@ package oauth
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strings"
-	"sync"
 
 	"github.com/hyprmcp/mcp-gateway/config"
 	"github.com/hyprmcp/mcp-gateway/log"
@@ -16,8 +14,6 @@ import (
 
 const ProtectedResourcePath = "/.well-known/oauth-protected-resource/"
 
-var upstreamMetadataURLs sync.Map
-
 type ProtectedResourceMetadata struct {
 	Resource             string         `json:"resource"`
 	AuthorizationServers []string       `json:"authorization_servers"`
@@ -28,9 +24,12 @@ type ProtectedResourceMetadata struct {
 // locations of authorization servers.
 //
 // Should be used to create a handler for the /.well-known/oauth-protected-resource endpoint.
-func NewProtectedResourceHandler(config *config.Config) http.Handler {
+//
+// If an upstream server announced its own metadata, which is remembered in upstreamMetadata, that metadata is served
+// for the resource instead.
+func NewProtectedResourceHandler(config *config.Config, upstreamMetadata UpstreamMetadataStore) http.Handler {
 	return http.StripPrefix(
-		ProtectedResourcePath,
+		strings.TrimSuffix(ProtectedResourcePath, "/"),
 		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if r.Method != http.MethodGet {
 				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -43,46 +42,47 @@ func NewProtectedResourceHandler(config *config.Config) http.Handler {
 			}
 
 			var response ProtectedResourceMetadata
-			if upstream, ok := upstreamMetadataURLs.Load(strings.Trim(r.URL.Path, `/`)); ok {
-				fmt.Println("loaded", upstream)
-				if upstreamStr, ok := upstream.(string); ok {
-					if req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, upstreamStr, nil); err != nil {
-						log.Get(r.Context()).Error(err, "failed to create request")
-						http.Error(w, "failed to create request", http.StatusBadGateway)
-						return
-					} else if resp, err := http.DefaultClient.Do(req); err != nil {
-						log.Get(r.Context()).Error(err, "failed to fetch metadata")
-						http.Error(w, "failed to fetch metadata", http.StatusBadGateway)
-						return
-					} else if resp.StatusCode != http.StatusOK {
-						log.Get(r.Context()).Error(errors.New("upstream returned non-200 status code"), "upstream returned non-200 status code")
-						for key, values := range resp.Header {
-							for _, val := range values {
-								w.Header().Set(key, val)
-							}
-						}
-						w.WriteHeader(resp.StatusCode)
-						io.Copy(w, resp.Body)
-						return
-					} else {
-						defer resp.Body.Close()
-
-						if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-							log.Get(r.Context()).Error(err, "failed to decode metadata")
-							http.Error(w, "failed to decode metadata", http.StatusInternalServerError)
-							return
+			if upstreamStr, ok, err := upstreamMetadata.Get(r.Context(), upstreamMetadataKey(config, r.URL.Path)); err != nil {
+				log.Get(r.Context()).Error(err, "failed to look up upstream metadata URL")
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			} else if ok {
+				if req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, upstreamStr, nil); err != nil {
+					log.Get(r.Context()).Error(err, "failed to create request")
+					http.Error(w, "failed to create request", http.StatusBadGateway)
+					return
+				} else if resp, err := http.DefaultClient.Do(req); err != nil {
+					log.Get(r.Context()).Error(err, "failed to fetch metadata")
+					http.Error(w, "failed to fetch metadata", http.StatusBadGateway)
+					return
+				} else if resp.StatusCode != http.StatusOK {
+					log.Get(r.Context()).Error(errors.New("upstream returned non-200 status code"), "upstream returned non-200 status code")
+					for key, values := range resp.Header {
+						for _, val := range values {
+							w.Header().Set(key, val)
 						}
 					}
-				} else {
-					log.Get(r.Context()).Error(errors.New("upstream is not a string"), "upstream is not a string")
-					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					w.WriteHeader(resp.StatusCode)
+					io.Copy(w, resp.Body)
 					return
+				} else {
+					defer resp.Body.Close()
+
+					if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+						log.Get(r.Context()).Error(err, "failed to decode metadata")
+						http.Error(w, "failed to decode metadata", http.StatusInternalServerError)
+						return
+					}
 				}
 			} else {
-				if config.Authorization.ServerMetadataProxyEnabled {
-					response.AuthorizationServers = []string{config.Host.String()}
+				if scope := scopeFor(config, r.URL.Path); scope.authorization.ServerMetadataProxyEnabled {
+					issuerURL, _ := url.Parse(config.Host.String())
+					if scope.prefix != "" {
+						issuerURL = issuerURL.JoinPath(scope.prefix)
+					}
+					response.AuthorizationServers = []string{issuerURL.String()}
 				} else {
-					response.AuthorizationServers = []string{config.Authorization.Server}
+					response.AuthorizationServers = []string{scope.authorization.Server}
 				}
 			}
 
@@ -0,0 +1,119 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dexidp/dex/api/v2"
+	"github.com/hyprmcp/mcp-gateway/config"
+)
+
+// RegistrationBackend creates clients at the authorization server for dynamic client registration requests.
+type RegistrationBackend interface {
+	// RegisterClient creates a new client with the given metadata and returns the information of the created client,
+	// including its client ID and, for confidential clients, its secret.
+	RegisterClient(ctx context.Context, client ClientInformation) (*ClientInformation, error)
+	// UpdateClient replaces the metadata of an existing client. The client secret cannot be changed.
+	UpdateClient(ctx context.Context, client ClientInformation) (*ClientInformation, error)
+	// DeleteClient deletes a client. It is not an error if the client does not exist.
+	DeleteClient(ctx context.Context, clientID string) error
+}
+
+// upstreamClientResolver is implemented by registration backends that issue their own client IDs and map them onto a
+// client of the authorization server. The authorization and token endpoints of the gateway use it to replace the
+// client credentials before requests are forwarded to the authorization server.
+type upstreamClientResolver interface {
+	resolveUpstreamClient(ctx context.Context, clientID string) (id string, secret string, err error)
+}
+
+// usesGatewayClients returns true if the client IDs of the given authorization configuration are issued by the gateway
+// and must be mapped onto an upstream client by the gateway's authorization and token endpoints.
+func usesGatewayClients(auth *config.Authorization) bool {
+	dcr := auth.GetDynamicClientRegistration()
+	return dcr.Enabled && dcr.GetBackend() == config.RegistrationBackendStatic
+}
+
+func isDexBackend(dcr config.DynamicClientRegistration) bool {
+	return dcr.GetBackend() == config.RegistrationBackendDex
+}
+
+// newRegistrationBackend creates the registration backend for the given scope. All connections are closed when ctx
+// is canceled.
+func newRegistrationBackend(ctx context.Context, scope authorizationScope, store ClientStore) (RegistrationBackend, error) {
+	dcr := scope.authorization.GetDynamicClientRegistration()
+	switch dcr.GetBackend() {
+	case config.RegistrationBackendDex:
+		return newDexRegistrationBackend(ctx, scope.dexGRPCClient, dcr.PublicClient)
+	case config.RegistrationBackendKeycloak:
+		return newKeycloakRegistrationBackend(dcr.Keycloak, dcr.PublicClient), nil
+	case config.RegistrationBackendStatic:
+		return newStaticRegistrationBackend(dcr.Static, store), nil
+	default:
+		return nil, fmt.Errorf("unknown dynamic client registration backend %q", dcr.Backend)
+	}
+}
+
+type dexRegistrationBackend struct {
+	client       api.DexClient
+	publicClient bool
+}
+
+func newDexRegistrationBackend(ctx context.Context, cfg *config.DexGRPCClient, publicClient bool) (*dexRegistrationBackend, error) {
+	if conn, err := newDexGRPCConn(cfg); err != nil {
+		return nil, err
+	} else {
+		go func() {
+			<-ctx.Done()
+			_ = conn.Close()
+		}()
+		return &dexRegistrationBackend{client: api.NewDexClient(conn), publicClient: publicClient}, nil
+	}
+}
+
+func (b *dexRegistrationBackend) RegisterClient(ctx context.Context, info ClientInformation) (*ClientInformation, error) {
+	client := api.Client{
+		Id:           genRandom(),
+		Name:         info.ClientName,
+		LogoUrl:      info.LogoURI,
+		RedirectUris: info.RedirectURIs,
+		Public:       true,
+	}
+
+	if !b.publicClient {
+		client.Secret = genRandom()
+	}
+
+	if resp, err := b.client.CreateClient(ctx, &api.CreateClientReq{Client: &client}); err != nil {
+		return nil, err
+	} else {
+		return &ClientInformation{
+			ClientID:     resp.Client.Id,
+			ClientSecret: resp.Client.Secret,
+			ClientName:   resp.Client.Name,
+			RedirectURIs: resp.Client.RedirectUris,
+			LogoURI:      resp.Client.LogoUrl,
+		}, nil
+	}
+}
+
+func (b *dexRegistrationBackend) UpdateClient(ctx context.Context, info ClientInformation) (*ClientInformation, error) {
+	req := api.UpdateClientReq{
+		Id:           info.ClientID,
+		Name:         info.ClientName,
+		LogoUrl:      info.LogoURI,
+		RedirectUris: info.RedirectURIs,
+	}
+
+	if resp, err := b.client.UpdateClient(ctx, &req); err != nil {
+		return nil, err
+	} else if resp.NotFound {
+		return nil, ErrClientNotFound
+	} else {
+		return &info, nil
+	}
+}
+
+func (b *dexRegistrationBackend) DeleteClient(ctx context.Context, clientID string) error {
+	_, err := b.client.DeleteClient(ctx, &api.DeleteClientReq{Id: clientID})
+	return err
+}
@@ -0,0 +1,238 @@
+package oauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyprmcp/mcp-gateway/config"
+)
+
+// keycloakRegistrationBackend creates clients with the admin REST API of Keycloak. It authenticates with the client
+// credentials grant of the configured service account client.
+type keycloakRegistrationBackend struct {
+	config       *config.KeycloakRegistration
+	publicClient bool
+	httpClient   *http.Client
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+func newKeycloakRegistrationBackend(cfg *config.KeycloakRegistration, publicClient bool) *keycloakRegistrationBackend {
+	return &keycloakRegistrationBackend{
+		config:       cfg,
+		publicClient: publicClient,
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// keycloakClientRepresentation is the subset of Keycloak's ClientRepresentation that is used by the gateway.
+type keycloakClientRepresentation struct {
+	ClientID                  string            `json:"clientId"`
+	Name                      string            `json:"name,omitempty"`
+	Secret                    string            `json:"secret,omitempty"`
+	RedirectURIs              []string          `json:"redirectUris"`
+	PublicClient              bool              `json:"publicClient"`
+	StandardFlowEnabled       bool              `json:"standardFlowEnabled"`
+	DirectAccessGrantsEnabled bool              `json:"directAccessGrantsEnabled"`
+	Attributes                map[string]string `json:"attributes,omitempty"`
+}
+
+func (b *keycloakRegistrationBackend) RegisterClient(ctx context.Context, info ClientInformation) (*ClientInformation, error) {
+	client := keycloakClientRepresentation{
+		ClientID:            genRandom(),
+		Name:                info.ClientName,
+		RedirectURIs:        info.RedirectURIs,
+		PublicClient:        b.publicClient,
+		StandardFlowEnabled: true,
+		Attributes:          map[string]string{"pkce.code.challenge.method": "S256"},
+	}
+
+	if info.LogoURI != "" {
+		client.Attributes["logoUri"] = info.LogoURI
+	}
+
+	if !b.publicClient {
+		client.Secret = genRandom()
+	}
+
+	body, err := json.Marshal(client)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp, err := b.do(ctx, http.MethodPost, "clients", bytes.NewReader(body)); err != nil {
+		return nil, err
+	} else {
+		_ = resp.Body.Close()
+	}
+
+	return &ClientInformation{
+		ClientID:     client.ClientID,
+		ClientSecret: client.Secret,
+		ClientName:   client.Name,
+		RedirectURIs: client.RedirectURIs,
+		LogoURI:      info.LogoURI,
+	}, nil
+}
+
+func (b *keycloakRegistrationBackend) UpdateClient(ctx context.Context, info ClientInformation) (*ClientInformation, error) {
+	id, err := b.lookupID(ctx, info.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.do(ctx, http.MethodGet, "clients/"+id, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// the representation is updated in place to keep all fields that are not managed by the gateway
+	var client map[string]any
+	err = json.NewDecoder(resp.Body).Decode(&client)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	client["name"] = info.ClientName
+	client["redirectUris"] = info.RedirectURIs
+	attributes, _ := client["attributes"].(map[string]any)
+	if attributes == nil {
+		attributes = map[string]any{}
+	}
+	attributes["logoUri"] = info.LogoURI
+	client["attributes"] = attributes
+
+	if body, err := json.Marshal(client); err != nil {
+		return nil, err
+	} else if resp, err := b.do(ctx, http.MethodPut, "clients/"+id, bytes.NewReader(body)); err != nil {
+		return nil, err
+	} else {
+		_ = resp.Body.Close()
+	}
+
+	return &info, nil
+}
+
+func (b *keycloakRegistrationBackend) DeleteClient(ctx context.Context, clientID string) error {
+	if id, err := b.lookupID(ctx, clientID); errors.Is(err, ErrClientNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	} else if resp, err := b.do(ctx, http.MethodDelete, "clients/"+id, nil); err != nil {
+		return err
+	} else {
+		_ = resp.Body.Close()
+		return nil
+	}
+}
+
+// lookupID returns Keycloak's internal ID of the client with the given client ID.
+func (b *keycloakRegistrationBackend) lookupID(ctx context.Context, clientID string) (string, error) {
+	resp, err := b.do(ctx, http.MethodGet, "clients?clientId="+url.QueryEscape(clientID), nil)
+	if err != nil {
+		return "", err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	var clients []struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&clients); err != nil {
+		return "", err
+	} else if len(clients) == 0 {
+		return "", ErrClientNotFound
+	} else {
+		return clients[0].ID, nil
+	}
+}
+
+// do sends a request to the admin API of the configured realm. The response body must be closed by the caller.
+func (b *keycloakRegistrationBackend) do(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	token, err := b.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pathOnly, query, _ := strings.Cut(path, "?")
+	u := (*url.URL)(b.config.URL).JoinPath("admin", "realms", b.config.Realm, pathOnly)
+	u.RawQuery = query
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if resp, err := b.httpClient.Do(req); err != nil {
+		return nil, err
+	} else if resp.StatusCode >= http.StatusBadRequest {
+		defer func() { _ = resp.Body.Close() }()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("keycloak admin API %v %v: %v: %s", method, path, resp.Status, msg)
+	} else {
+		return resp, nil
+	}
+}
+
+// accessToken returns a cached access token for the admin API or requests a new one, if it has expired.
+func (b *keycloakRegistrationBackend) accessToken(ctx context.Context) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.token != "" && time.Now().Before(b.tokenExpiry) {
+		return b.token, nil
+	}
+
+	tokenURL := (*url.URL)(b.config.URL).JoinPath("realms", b.config.Realm, "protocol", "openid-connect", "token")
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {b.config.ClientID},
+		"client_secret": {string(b.config.ClientSecret)},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL.String(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("keycloak token request failed: %w", err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("keycloak token request failed: %v", resp.Status)
+	}
+
+	var tokenResponse struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
+		return "", fmt.Errorf("keycloak token response is invalid: %w", err)
+	}
+
+	b.token = tokenResponse.AccessToken
+	// renew the token a little before it actually expires
+	b.tokenExpiry = time.Now().Add(time.Duration(tokenResponse.ExpiresIn)*time.Second - 10*time.Second)
+	return b.token, nil
+}
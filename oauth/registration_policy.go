@@ -0,0 +1,272 @@
+package oauth
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/hyprmcp/mcp-gateway/config"
+)
+
+// RegistrationError is an error response of the client registration endpoint as defined in RFC 7591, Section 3.2.2.
+type RegistrationError struct {
+	Code        string
+	Description string
+}
+
+func (e *RegistrationError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func invalidRedirectURI(format string, args ...any) *RegistrationError {
+	return &RegistrationError{Code: "invalid_redirect_uri", Description: fmt.Sprintf(format, args...)}
+}
+
+func invalidClientMetadata(format string, args ...any) *RegistrationError {
+	return &RegistrationError{Code: "invalid_client_metadata", Description: fmt.Sprintf(format, args...)}
+}
+
+var defaultGrantTypes = []string{"authorization_code", "refresh_token"}
+
+// registrationPolicy checks the metadata of clients before they are registered or updated.
+type registrationPolicy struct {
+	config             config.RegistrationPolicy
+	allowLoopback      bool
+	redirectURIs       []*redirectURIPattern
+	grantTypes         []string
+	tokenEndpointAuths []string
+}
+
+func newRegistrationPolicy(dcr config.DynamicClientRegistration) *registrationPolicy {
+	p := &registrationPolicy{grantTypes: defaultGrantTypes}
+	if dcr.Policy != nil {
+		p.config = *dcr.Policy
+	}
+
+	for _, pattern := range p.config.RedirectURIs {
+		if pattern == "loopback" {
+			p.allowLoopback = true
+		} else {
+			p.redirectURIs = append(p.redirectURIs, compileRedirectURIPattern(pattern))
+		}
+	}
+
+	if len(p.config.GrantTypes) > 0 {
+		p.grantTypes = p.config.GrantTypes
+	}
+
+	// Dex clients are always public, so they can be used without the secret
+	if dcr.GetBackend() == config.RegistrationBackendStatic || dcr.PublicClient {
+		p.tokenEndpointAuths = []string{"none"}
+	} else if dcr.GetBackend() == config.RegistrationBackendDex {
+		p.tokenEndpointAuths = []string{"none", "client_secret_basic", "client_secret_post"}
+	} else {
+		p.tokenEndpointAuths = []string{"client_secret_basic", "client_secret_post"}
+	}
+
+	return p
+}
+
+// redirectURIPattern is a pattern for redirect URIs. The scheme, host and path of redirect URIs are matched separately,
+// so that wildcards can't match other parts of the URI than intended.
+type redirectURIPattern struct {
+	scheme string
+	// host is nil if the pattern has no authority, e.g. for private-use URI schemes like com.example.app:/callback.
+	host *regexp.Regexp
+	// path matches the path and the query of redirect URIs.
+	path *regexp.Regexp
+}
+
+// compileRedirectURIPattern compiles a pattern of RegistrationPolicy.RedirectURIs. In the host, * matches any
+// characters of a host label and ** matches any host. In the path, * matches any characters except / and ** matches
+// any characters.
+func compileRedirectURIPattern(pattern string) *redirectURIPattern {
+	scheme, rest, _ := strings.Cut(pattern, ":")
+	p := &redirectURIPattern{scheme: strings.ToLower(scheme)}
+
+	if authority, ok := strings.CutPrefix(rest, "//"); ok {
+		host := authority
+		rest = ""
+		if i := strings.IndexAny(authority, "/?"); i >= 0 {
+			host, rest = authority[:i], authority[i:]
+		}
+		p.host = compileGlob(strings.ToLower(host), `[a-z0-9-]*`, `[a-z0-9.-]*`)
+	}
+
+	p.path = compileGlob(rest, `[^/]*`, `.*`)
+	return p
+}
+
+// matches returns true if the redirect URI matches the pattern.
+func (p *redirectURIPattern) matches(u *url.URL) bool {
+	if strings.ToLower(u.Scheme) != p.scheme {
+		return false
+	} else if p.host == nil && u.Host != "" {
+		return false
+	} else if p.host != nil && (u.Host == "" || !p.host.MatchString(strings.ToLower(u.Host))) {
+		return false
+	}
+
+	path := u.EscapedPath()
+	if u.Opaque != "" {
+		path = u.Opaque
+	}
+	if u.ForceQuery || u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+
+	return p.path.MatchString(path)
+}
+
+// compileGlob converts a pattern to a regular expression, in which * and ** are replaced with the given expressions.
+func compileGlob(pattern string, star string, doubleStar string) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i, part := range strings.Split(pattern, "**") {
+		if i > 0 {
+			sb.WriteString(doubleStar)
+		}
+		for j, subPart := range strings.Split(part, "*") {
+			if j > 0 {
+				sb.WriteString(star)
+			}
+			sb.WriteString(regexp.QuoteMeta(subPart))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
+
+// check returns a RegistrationError if the client metadata violates the policy.
+func (p *registrationPolicy) check(client *ClientInformation) *RegistrationError {
+	fields := map[string]bool{
+		"client_name":   client.ClientName != "",
+		"logo_uri":      client.LogoURI != "",
+		"redirect_uris": len(client.RedirectURIs) > 0,
+		"grant_types":   len(client.GrantTypes) > 0,
+		"scope":         client.Scope != "",
+	}
+	for _, f := range p.config.RequiredFields {
+		if !fields[f] {
+			return invalidClientMetadata("%v is required", f)
+		}
+	}
+
+	if limit := p.config.MaxClientNameLength; limit > 0 && len(client.ClientName) > limit {
+		return invalidClientMetadata("client_name must not be longer than %v characters", limit)
+	}
+
+	if err := p.checkRedirectURIs(client.RedirectURIs); err != nil {
+		return err
+	}
+
+	if client.LogoURI != "" {
+		if err := p.checkLogoURI(client.LogoURI); err != nil {
+			return err
+		}
+	}
+
+	for _, grantType := range client.GrantTypes {
+		if !slices.Contains(p.grantTypes, grantType) {
+			return invalidClientMetadata("grant type %q is not supported, must be one of %v", grantType, p.grantTypes)
+		}
+	}
+
+	for _, responseType := range client.ResponseTypes {
+		if responseType != "code" {
+			return invalidClientMetadata("response type %q is not supported", responseType)
+		}
+	}
+
+	if method := client.TokenEndpointAuthMethod; method != "" && !slices.Contains(p.tokenEndpointAuths, method) {
+		return invalidClientMetadata("token_endpoint_auth_method %q is not supported, must be one of %v",
+			method, p.tokenEndpointAuths)
+	}
+
+	return nil
+}
+
+func (p *registrationPolicy) checkRedirectURIs(redirectURIs []string) *RegistrationError {
+	if len(redirectURIs) == 0 {
+		return invalidRedirectURI("at least one redirect URI is required")
+	} else if limit := p.config.MaxRedirectURIs; limit > 0 && len(redirectURIs) > limit {
+		return invalidRedirectURI("at most %v redirect URIs are allowed", limit)
+	}
+
+	for _, redirectURI := range redirectURIs {
+		if limit := p.config.MaxURILength; limit > 0 && len(redirectURI) > limit {
+			return invalidRedirectURI("redirect URI must not be longer than %v characters", limit)
+		}
+
+		u, err := url.Parse(redirectURI)
+		if err != nil || !u.IsAbs() {
+			return invalidRedirectURI("redirect URI %q is not an absolute URI", redirectURI)
+		} else if strings.Contains(redirectURI, "#") {
+			return invalidRedirectURI("redirect URI %q must not contain a fragment", redirectURI)
+		} else if u.User != nil {
+			return invalidRedirectURI("redirect URI %q must not contain user information", redirectURI)
+		}
+
+		if len(p.config.RedirectURIs) == 0 {
+			continue
+		} else if p.allowLoopback && isLoopbackURI(u) {
+			continue
+		} else if !slices.ContainsFunc(p.redirectURIs, func(pattern *redirectURIPattern) bool { return pattern.matches(u) }) {
+			return invalidRedirectURI("redirect URI %q is not allowed", redirectURI)
+		}
+	}
+
+	return nil
+}
+
+func (p *registrationPolicy) checkLogoURI(logoURI string) *RegistrationError {
+	if limit := p.config.MaxURILength; limit > 0 && len(logoURI) > limit {
+		return invalidClientMetadata("logo_uri must not be longer than %v characters", limit)
+	}
+
+	u, err := url.Parse(logoURI)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return invalidClientMetadata("logo_uri must be an absolute http or https URL")
+	}
+
+	if len(p.config.LogoHosts) == 0 {
+		return nil
+	}
+
+	if !hostAllowed(u.Hostname(), p.config.LogoHosts) {
+		return invalidClientMetadata("logo_uri host %q is not allowed", u.Hostname())
+	}
+
+	return nil
+}
+
+// hostAllowed returns true if host matches one of the patterns, which are either host names or "*." followed by a
+// domain, which matches all of its subdomains.
+func hostAllowed(host string, patterns []string) bool {
+	host = strings.ToLower(host)
+	for _, allowed := range patterns {
+		allowed = strings.ToLower(allowed)
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok && strings.HasSuffix(host, "."+suffix) {
+			return true
+		} else if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// isLoopbackURI returns true for http URIs with a loopback host as described in RFC 8252, Section 7.3.
+func isLoopbackURI(u *url.URL) bool {
+	if u.Scheme != "http" {
+		return false
+	} else if host := u.Hostname(); host == "localhost" {
+		return true
+	} else if ip := net.ParseIP(host); ip != nil {
+		return ip.IsLoopback()
+	} else {
+		return false
+	}
+}
@@ -0,0 +1,45 @@
+package oauth
+
+import (
+	"context"
+
+	"github.com/hyprmcp/mcp-gateway/config"
+)
+
+// staticRegistrationBackend issues client IDs that are managed by the gateway. All of them are mapped onto a single
+// client that was registered at the authorization server beforehand, so that dynamic client registration can be used
+// with authorization servers that don't support it.
+type staticRegistrationBackend struct {
+	config *config.StaticRegistration
+	store  ClientStore
+}
+
+func newStaticRegistrationBackend(cfg *config.StaticRegistration, store ClientStore) *staticRegistrationBackend {
+	return &staticRegistrationBackend{config: cfg, store: store}
+}
+
+func (b *staticRegistrationBackend) RegisterClient(ctx context.Context, info ClientInformation) (*ClientInformation, error) {
+	// clients of the gateway are always public, because the gateway adds the secret of the upstream client
+	return &ClientInformation{
+		ClientID:     genRandom(),
+		ClientName:   info.ClientName,
+		RedirectURIs: info.RedirectURIs,
+		LogoURI:      info.LogoURI,
+	}, nil
+}
+
+func (b *staticRegistrationBackend) UpdateClient(ctx context.Context, info ClientInformation) (*ClientInformation, error) {
+	info.ClientSecret = ""
+	return &info, nil
+}
+
+func (b *staticRegistrationBackend) DeleteClient(ctx context.Context, clientID string) error {
+	return nil
+}
+
+func (b *staticRegistrationBackend) resolveUpstreamClient(ctx context.Context, clientID string) (string, string, error) {
+	if _, err := b.store.GetRegistration(ctx, clientID); err != nil {
+		return "", "", err
+	}
+	return b.config.ClientID, string(b.config.ClientSecret), nil
+}
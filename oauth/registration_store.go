@@ -0,0 +1,136 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var ErrClientNotFound = errors.New("client not found")
+
+// Registration is the gateway's record of a dynamically registered client.
+type Registration struct {
+	Client ClientInformation `json:"client"`
+	// MetadataDocumentURL is set for clients that were registered by the gateway for a client ID metadata document.
+	MetadataDocumentURL string `json:"metadataDocumentUrl,omitempty"`
+	// RegistrationAccessTokenHash is the SHA-256 hash of the registration access token, which is needed to read,
+	// update or delete the client.
+	RegistrationAccessTokenHash string    `json:"registrationAccessTokenHash"`
+	CreatedAt                   time.Time `json:"createdAt"`
+	LastUsedAt                  time.Time `json:"lastUsedAt"`
+}
+
+// ClientStore persists the registrations of dynamically registered clients.
+type ClientStore interface {
+	// GetRegistration returns the registration of the client with the given ID or ErrClientNotFound.
+	GetRegistration(ctx context.Context, clientID string) (*Registration, error)
+	// PutRegistration creates or replaces a registration.
+	PutRegistration(ctx context.Context, registration Registration) error
+	// DeleteRegistration deletes a registration. It is not an error if it does not exist.
+	DeleteRegistration(ctx context.Context, clientID string) error
+	// ListRegistrations returns all registrations.
+	ListRegistrations(ctx context.Context) ([]Registration, error)
+}
+
+// fileClientStore keeps all registrations in memory and writes them to a JSON file after every change, if a path is
+// set.
+type fileClientStore struct {
+	path          string
+	mu            sync.RWMutex
+	registrations map[string]Registration
+}
+
+func newFileClientStore(path string) (*fileClientStore, error) {
+	store := &fileClientStore{path: path, registrations: map[string]Registration{}}
+	if path == "" {
+		return store, nil
+	}
+
+	if data, err := os.ReadFile(path); errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read client store: %w", err)
+	} else if err := json.Unmarshal(data, &store.registrations); err != nil {
+		return nil, fmt.Errorf("failed to parse client store %v: %w", path, err)
+	}
+
+	return store, nil
+}
+
+func (s *fileClientStore) GetRegistration(ctx context.Context, clientID string) (*Registration, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if registration, ok := s.registrations[clientID]; !ok {
+		return nil, ErrClientNotFound
+	} else {
+		return &registration, nil
+	}
+}
+
+func (s *fileClientStore) PutRegistration(ctx context.Context, registration Registration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.registrations[registration.Client.ClientID] = registration
+	return s.save()
+}
+
+func (s *fileClientStore) DeleteRegistration(ctx context.Context, clientID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.registrations[clientID]; !ok {
+		return nil
+	}
+
+	delete(s.registrations, clientID)
+	return s.save()
+}
+
+func (s *fileClientStore) ListRegistrations(ctx context.Context) ([]Registration, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]Registration, 0, len(s.registrations))
+	for _, registration := range s.registrations {
+		result = append(result, registration)
+	}
+	return result, nil
+}
+
+// save writes all registrations to a temporary file which then replaces the store file, so that the store file is
+// never left in a partially written state.
+func (s *fileClientStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.registrations, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write client store: %w", err)
+	}
+
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write client store: %w", err)
+	} else if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write client store: %w", err)
+	} else if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write client store: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hyprmcp/mcp-gateway/log"
+)
+
+const TokenPath = "/oauth/token"
+
+// NewTokenHandler forwards token requests to the token endpoint of the authorization server after replacing the
+// client credentials of gateway-issued clients and client metadata documents with those of the upstream client. All
+// other requests are forwarded unchanged.
+func NewTokenHandler(registry *ClientRegistry, metadata *MetadataCache) (http.Handler, error) {
+	if !registry.mapsClientIDs() {
+		return nil, errors.New("client IDs are not mapped by the gateway")
+	} else if _, err := tokenEndpoint(metadata.Get()); err != nil {
+		return nil, err
+	}
+
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		} else if err := r.ParseForm(); err != nil {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "invalid form body")
+			return
+		}
+
+		form := r.PostForm
+		clientID := form.Get("client_id")
+		if id, _, ok := r.BasicAuth(); ok && clientID == "" {
+			clientID = id
+		}
+
+		upstreamID, upstreamSecret, mapped, err := registry.resolveUpstreamClient(r.Context(), clientID)
+		if errors.Is(err, ErrClientNotFound) {
+			writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "unknown client")
+			return
+		} else if err != nil {
+			log.Get(r.Context()).Error(err, "failed to resolve upstream client")
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+
+		registry.Touch(r.Context(), clientID)
+		if mapped {
+			form.Set("client_id", upstreamID)
+			if upstreamSecret != "" {
+				form.Set("client_secret", upstreamSecret)
+			} else {
+				form.Del("client_secret")
+			}
+		}
+
+		// the token endpoint is looked up for every request, because it might change when the metadata is refreshed
+		endpoint, err := tokenEndpoint(metadata.Get())
+		if err != nil {
+			log.Get(r.Context()).Error(err, "invalid authorization server metadata")
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+
+		req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+		if err != nil {
+			log.Get(r.Context()).Error(err, "failed to create upstream token request")
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		if authorization := r.Header.Get("Authorization"); authorization != "" && !mapped {
+			req.Header.Set("Authorization", authorization)
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			log.Get(r.Context()).Error(err, "upstream token request failed")
+			writeOAuthError(w, http.StatusBadGateway, "server_error", "")
+			return
+		}
+
+		defer func() { _ = resp.Body.Close() }()
+
+		for _, h := range []string{"Content-Type", "Cache-Control", "Pragma"} {
+			if v := resp.Header.Get(h); v != "" {
+				w.Header().Set(h, v)
+			}
+		}
+		w.WriteHeader(resp.StatusCode)
+		if _, err := io.Copy(w, resp.Body); err != nil {
+			log.Get(r.Context()).Error(err, "failed to copy upstream token response")
+		}
+	}), nil
+}
+
+// tokenEndpoint returns the token endpoint of the authorization server metadata.
+func tokenEndpoint(metadata map[string]any) (string, error) {
+	if endpoint, ok := metadata["token_endpoint"].(string); !ok {
+		return "", errors.New("authorization metadata is missing token_endpoint field")
+	} else if _, err := url.Parse(endpoint); err != nil {
+		return "", fmt.Errorf("could not parse token endpoint: %w", err)
+	} else {
+		return endpoint, nil
+	}
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	resp := map[string]string{"error": code}
+	if description != "" {
+		resp["error_description"] = description
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,198 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hyprmcp/mcp-gateway/config"
+)
+
+// UpstreamMetadataStore remembers the URLs of the protected resource metadata that upstream MCP servers announce in
+// their WWW-Authenticate headers, so that the gateway can serve the upstream metadata for the proxied resource.
+//
+// Keys are the URLs of the proxied resources at the gateway. Implementations must be safe for concurrent use and
+// should forget entries after a TTL. To share the entries between replicas behind a load balancer, the interface can be
+// implemented with a key-value store such as Redis, e.g. with SET and an expiry for Set and GET for Get.
+type UpstreamMetadataStore interface {
+	// Get returns the metadata URL for the given resource and true, or false if it is unknown or has expired.
+	Get(ctx context.Context, resource string) (string, bool, error)
+	// Set stores the metadata URL for the given resource and resets its TTL.
+	Set(ctx context.Context, resource, metadataURL string) error
+}
+
+const defaultUpstreamMetadataTTL = 24 * time.Hour
+
+// NewUpstreamMetadataStore creates the store that is configured in cfg. It is kept in memory unless a store file is
+// configured.
+func NewUpstreamMetadataStore(cfg *config.UpstreamMetadata) (UpstreamMetadataStore, error) {
+	ttl := defaultUpstreamMetadataTTL
+	if cfg != nil && cfg.TTL > 0 {
+		ttl = cfg.TTL
+	}
+
+	if cfg != nil && cfg.StoreFile != "" {
+		return newFileUpstreamMetadataStore(cfg.StoreFile, ttl)
+	} else {
+		return newMemoryUpstreamMetadataStore(ttl), nil
+	}
+}
+
+type upstreamMetadataEntry struct {
+	MetadataURL string    `json:"metadataUrl"`
+	Expires     time.Time `json:"expires"`
+}
+
+// memoryUpstreamMetadataStore keeps all entries in memory. Expired entries are removed whenever an entry is set.
+type memoryUpstreamMetadataStore struct {
+	ttl     time.Duration
+	mu      sync.RWMutex
+	entries map[string]upstreamMetadataEntry
+}
+
+func newMemoryUpstreamMetadataStore(ttl time.Duration) *memoryUpstreamMetadataStore {
+	return &memoryUpstreamMetadataStore{ttl: ttl, entries: map[string]upstreamMetadataEntry{}}
+}
+
+func (s *memoryUpstreamMetadataStore) Get(ctx context.Context, resource string) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if entry, ok := s.entries[resource]; !ok || time.Now().After(entry.Expires) {
+		return "", false, nil
+	} else {
+		return entry.MetadataURL, true, nil
+	}
+}
+
+func (s *memoryUpstreamMetadataStore) Set(ctx context.Context, resource, metadataURL string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removeExpiredUpstreamMetadata(s.entries)
+	s.entries[resource] = upstreamMetadataEntry{MetadataURL: metadataURL, Expires: time.Now().Add(s.ttl)}
+	return nil
+}
+
+// fileUpstreamMetadataStore keeps all entries in a JSON file. The file is read again when it was modified by another
+// process, so that replicas that share the file also share the entries. Concurrent writes by different processes may
+// lose entries, which are then stored again by the next response of the upstream server.
+type fileUpstreamMetadataStore struct {
+	path    string
+	ttl     time.Duration
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	entries map[string]upstreamMetadataEntry
+}
+
+func newFileUpstreamMetadataStore(path string, ttl time.Duration) (*fileUpstreamMetadataStore, error) {
+	s := &fileUpstreamMetadataStore{path: path, ttl: ttl, entries: map[string]upstreamMetadataEntry{}}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileUpstreamMetadataStore) Get(ctx context.Context, resource string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return "", false, err
+	} else if entry, ok := s.entries[resource]; !ok || time.Now().After(entry.Expires) {
+		return "", false, nil
+	} else {
+		return entry.MetadataURL, true, nil
+	}
+}
+
+func (s *fileUpstreamMetadataStore) Set(ctx context.Context, resource, metadataURL string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	// the file is only written if the entry changed or half of its TTL has passed, because Set is called for every
+	// unauthorized response
+	if entry, ok := s.entries[resource]; ok && entry.MetadataURL == metadataURL && time.Until(entry.Expires) > s.ttl/2 {
+		return nil
+	}
+
+	entries := maps.Clone(s.entries)
+	removeExpiredUpstreamMetadata(entries)
+	entries[resource] = upstreamMetadataEntry{MetadataURL: metadataURL, Expires: time.Now().Add(s.ttl)}
+	if err := s.save(entries); err != nil {
+		return err
+	}
+
+	s.entries = entries
+	return nil
+}
+
+// load reads the file if it was modified since it was last read.
+func (s *fileUpstreamMetadataStore) load() error {
+	info, err := os.Stat(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read upstream metadata store: %w", err)
+	} else if info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return nil
+	}
+
+	entries := map[string]upstreamMetadataEntry{}
+	if data, err := os.ReadFile(s.path); err != nil {
+		return fmt.Errorf("failed to read upstream metadata store: %w", err)
+	} else if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to parse upstream metadata store %v: %w", s.path, err)
+	}
+
+	s.entries = entries
+	s.modTime, s.size = info.ModTime(), info.Size()
+	return nil
+}
+
+// save writes the entries to a temporary file which then replaces the store file, so that other processes never read
+// a partially written file.
+func (s *fileUpstreamMetadataStore) save(entries map[string]upstreamMetadataEntry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write upstream metadata store: %w", err)
+	}
+
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write upstream metadata store: %w", err)
+	} else if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write upstream metadata store: %w", err)
+	} else if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write upstream metadata store: %w", err)
+	}
+
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime, s.size = info.ModTime(), info.Size()
+	}
+
+	return nil
+}
+
+func removeExpiredUpstreamMetadata(entries map[string]upstreamMetadataEntry) {
+	now := time.Now()
+	maps.DeleteFunc(entries, func(_ string, entry upstreamMetadataEntry) bool { return now.After(entry.Expires) })
+}
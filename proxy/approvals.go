@@ -0,0 +1,281 @@
+package proxy
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/hyprmcp/mcp-gateway/jsonrpc"
+	"github.com/hyprmcp/mcp-gateway/webhook"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+const (
+	// ApprovalCallbackPath is the path of the endpoint that receives the decisions for pending approvals. The ID of the
+	// approval is appended to it.
+	ApprovalCallbackPath = "/approvals/"
+
+	defaultApprovalTimeout = 5 * time.Minute
+	// elicitationIDPrefix marks the IDs of the elicitation requests that are sent by the gateway, so that the responses
+	// of the client can be told apart from responses to requests of the upstream server.
+	elicitationIDPrefix = "mcp-gateway-approval-"
+	// elicitationApproveField is the field of the elicitation form that must be set to true to approve a call.
+	elicitationApproveField = "approve"
+)
+
+var ErrApprovalNotFound = errors.New("approval not found")
+
+type ApprovalMethod string
+
+const (
+	ApprovalMethodWebhook     ApprovalMethod = "webhook"
+	ApprovalMethodElicitation ApprovalMethod = "elicitation"
+)
+
+// PendingApproval is a tools/call request that waits for an approval.
+type PendingApproval struct {
+	ID           string         `json:"id"`
+	Method       ApprovalMethod `json:"method"`
+	Path         string         `json:"path"`
+	Tool         string         `json:"tool"`
+	Subject      string         `json:"subject,omitempty"`
+	MCPSessionID string         `json:"mcpSessionId,omitempty"`
+	CreatedAt    time.Time      `json:"createdAt"`
+	ExpiresAt    time.Time      `json:"expiresAt"`
+
+	decision chan webhook.ApprovalDecision
+	// callbackSecret is the secret that the decisions of the approval webhook are signed with.
+	callbackSecret string
+}
+
+// ApprovalStore keeps track of the tools/call requests that wait for an approval.
+//
+// A single ApprovalStore should be shared by all proxy handlers, so that pending approvals survive configuration
+// reloads.
+type ApprovalStore struct {
+	mu      sync.Mutex
+	pending map[string]*PendingApproval
+	// elicitationSessions contains the sessions whose clients support elicitation and when they were last used.
+	elicitationSessions map[string]time.Time
+}
+
+func NewApprovalStore() *ApprovalStore {
+	return &ApprovalStore{pending: map[string]*PendingApproval{}, elicitationSessions: map[string]time.Time{}}
+}
+
+// Pending returns all pending approvals, ordered by creation time.
+func (s *ApprovalStore) Pending() []PendingApproval {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]PendingApproval, 0, len(s.pending))
+	for _, a := range s.pending {
+		result = append(result, *a)
+	}
+
+	slices.SortFunc(result, func(a, b PendingApproval) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return result
+}
+
+// Decide approves or denies a pending approval.
+func (s *ApprovalStore) Decide(id string, decision webhook.ApprovalDecision) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a, ok := s.pending[id]; !ok {
+		return ErrApprovalNotFound
+	} else {
+		delete(s.pending, id)
+		a.decision <- decision
+		return nil
+	}
+}
+
+// CallbackHandler handles the decisions that are POSTed to ApprovalCallbackPath followed by the ID of the approval.
+// Decisions are only accepted for approvals that were sent to the approval webhook and must be signed with its callback
+// secret (see webhook.SignApprovalDecision).
+func (s *ApprovalStore) CallbackHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, "invalid approval decision", http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		a, ok := s.pending[id]
+		s.mu.Unlock()
+
+		var decision webhook.ApprovalDecision
+		signature := r.Header.Get(webhook.ApprovalSignatureHeader)
+		if !ok || a.Method != ApprovalMethodWebhook || a.callbackSecret == "" {
+			http.Error(w, ErrApprovalNotFound.Error(), http.StatusNotFound)
+		} else if !hmac.Equal([]byte(signature), []byte(webhook.SignApprovalDecision(a.callbackSecret, id, body))) {
+			http.Error(w, "invalid approval decision signature", http.StatusUnauthorized)
+		} else if err := json.Unmarshal(body, &decision); err != nil {
+			http.Error(w, "invalid approval decision", http.StatusBadRequest)
+		} else if err := s.Decide(id, decision); errors.Is(err, ErrApprovalNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+}
+
+// add creates a pending approval with a new ID.
+func (s *ApprovalStore) add(a *PendingApproval, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = defaultApprovalTimeout
+	}
+
+	a.ID = rand.Text()
+	a.CreatedAt = time.Now()
+	a.ExpiresAt = a.CreatedAt.Add(timeout)
+	a.decision = make(chan webhook.ApprovalDecision, 1)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[a.ID] = a
+}
+
+// remove removes a pending approval without deciding it.
+func (s *ApprovalStore) remove(a *PendingApproval) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pending, a.ID)
+}
+
+// wait waits until the approval is decided. It returns nil if the approval expired or ctx was canceled before.
+func (s *ApprovalStore) wait(ctx context.Context, a *PendingApproval) *webhook.ApprovalDecision {
+	timer := time.NewTimer(time.Until(a.ExpiresAt))
+	defer timer.Stop()
+
+	select {
+	case decision := <-a.decision:
+		return &decision
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	s.remove(a)
+
+	// the approval might have been decided after the timeout but before it was removed
+	select {
+	case decision := <-a.decision:
+		return &decision
+	default:
+		return nil
+	}
+}
+
+// decideElicitation decides the pending approval that the elicitation response of a client belongs to. It returns true
+// if the response belongs to an elicitation request of the gateway, in which case it must not be forwarded upstream.
+func (s *ApprovalStore) decideElicitation(sessionID string, resp *jsonrpc.Response) bool {
+	if !resp.ID.IsString || !strings.HasPrefix(resp.ID.Str, elicitationIDPrefix) {
+		return false
+	}
+
+	id := strings.TrimPrefix(resp.ID.Str, elicitationIDPrefix)
+
+	s.mu.Lock()
+	a, ok := s.pending[id]
+	s.mu.Unlock()
+
+	// approvals can only be decided by the session that the elicitation request was sent to
+	if !ok || a.Method != ApprovalMethodElicitation || a.MCPSessionID != sessionID {
+		return true
+	}
+
+	decision := webhook.ApprovalDecision{}
+	var result mcp.ElicitResult
+	if resp.Error != nil {
+		decision.Reason = resp.Error.Message
+	} else if resp.Result == nil {
+		decision.Reason = "elicitation response without result"
+	} else if err := json.Unmarshal(*resp.Result, &result); err != nil {
+		decision.Reason = "invalid elicitation result"
+	} else if result.Action != "accept" {
+		decision.Reason = "the user chose to " + result.Action
+	} else if approve, _ := result.Content[elicitationApproveField].(bool); !approve {
+		decision.Reason = "the user did not approve"
+	} else {
+		decision.Approved = true
+	}
+
+	_ = s.Decide(id, decision)
+	return true
+}
+
+// setElicitationSupport records that the client of the session supports elicitation.
+func (s *ApprovalStore) setElicitationSupport(sessionID string) {
+	if s == nil || sessionID == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	threshold := time.Now().Add(-sessionIdleTimeout)
+	for id, lastUsed := range s.elicitationSessions {
+		if lastUsed.Before(threshold) {
+			delete(s.elicitationSessions, id)
+		}
+	}
+
+	s.elicitationSessions[sessionID] = time.Now()
+}
+
+// supportsElicitation returns true if the client of the session supports elicitation.
+func (s *ApprovalStore) supportsElicitation(sessionID string) bool {
+	if s == nil || sessionID == "" {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.elicitationSessions[sessionID]; ok {
+		s.elicitationSessions[sessionID] = time.Now()
+		return true
+	}
+	return false
+}
+
+// elicitationRequest creates the elicitation request that asks the user to approve the call of a tool.
+func elicitationRequest(a *PendingApproval, arguments any) (*jsonrpc.Request, error) {
+	args, err := json.Marshal(arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := json.Marshal(&mcp.ElicitParams{
+		Message: "The tool " + a.Tool + " requires your approval. It is called with the arguments " + string(args),
+		RequestedSchema: &jsonschema.Schema{
+			Type: "object",
+			Properties: map[string]*jsonschema.Schema{
+				elicitationApproveField: {Type: "boolean", Title: "Approve the call of " + a.Tool},
+			},
+			Required: []string{elicitationApproveField},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &jsonrpc.Request{
+		ID:     jsonrpc2.ID{Str: elicitationIDPrefix + a.ID, IsString: true},
+		Method: "elicitation/create",
+		Params: (*json.RawMessage)(&params),
+	}, nil
+}
@@ -0,0 +1,292 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyprmcp/mcp-gateway/config"
+	"github.com/hyprmcp/mcp-gateway/webhook"
+)
+
+func TestCallbackHandler(t *testing.T) {
+	const body = `{"approved":true}`
+
+	tests := []struct {
+		name       string
+		method     ApprovalMethod
+		id         func(a *PendingApproval) string
+		signature  func(a *PendingApproval) string
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "signed",
+			method:     ApprovalMethodWebhook,
+			signature:  func(a *PendingApproval) string { return webhook.SignApprovalDecision("secret", a.ID, []byte(body)) },
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "unsigned",
+			method:     ApprovalMethodWebhook,
+			signature:  func(a *PendingApproval) string { return "" },
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "other secret",
+			method:     ApprovalMethodWebhook,
+			signature:  func(a *PendingApproval) string { return webhook.SignApprovalDecision("other", a.ID, []byte(body)) },
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "signature of other approval",
+			method:     ApprovalMethodWebhook,
+			signature:  func(a *PendingApproval) string { return webhook.SignApprovalDecision("secret", "other", []byte(body)) },
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:   "signature of other decision",
+			method: ApprovalMethodWebhook,
+			signature: func(a *PendingApproval) string {
+				return webhook.SignApprovalDecision("secret", a.ID, []byte(`{"approved":false}`))
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:   "unknown approval",
+			method: ApprovalMethodWebhook,
+			id:     func(a *PendingApproval) string { return "unknown" },
+			signature: func(a *PendingApproval) string {
+				return webhook.SignApprovalDecision("secret", "unknown", []byte(body))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "elicitation approval",
+			method:     ApprovalMethodElicitation,
+			signature:  func(a *PendingApproval) string { return webhook.SignApprovalDecision("", a.ID, []byte(body)) },
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:   "invalid decision",
+			method: ApprovalMethodWebhook,
+			body:   `approved`,
+			signature: func(a *PendingApproval) string {
+				return webhook.SignApprovalDecision("secret", a.ID, []byte(`approved`))
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewApprovalStore()
+			a := &PendingApproval{Method: tt.method}
+			if tt.method == ApprovalMethodWebhook {
+				a.callbackSecret = "secret"
+			}
+			s.add(a, time.Minute)
+
+			id, reqBody := a.ID, tt.body
+			if tt.id != nil {
+				id = tt.id(a)
+			}
+			if reqBody == "" {
+				reqBody = body
+			}
+
+			mux := http.NewServeMux()
+			mux.Handle("POST "+ApprovalCallbackPath+"{id}", s.CallbackHandler())
+			req := httptest.NewRequest(http.MethodPost, ApprovalCallbackPath+id, strings.NewReader(reqBody))
+			req.Header.Set(webhook.ApprovalSignatureHeader, tt.signature(a))
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %v, want %v", rec.Code, tt.wantStatus)
+			}
+
+			decided := len(s.Pending()) == 0
+			if want := tt.wantStatus == http.StatusNoContent; decided != want {
+				t.Errorf("decided = %v, want %v", decided, want)
+			}
+		})
+	}
+}
+
+func TestApprovalWait(t *testing.T) {
+	tests := []struct {
+		name        string
+		timeout     time.Duration
+		cancel      bool
+		decideAfter time.Duration
+		want        *webhook.ApprovalDecision
+	}{
+		{"decided before waiting", time.Minute, false, 0, &webhook.ApprovalDecision{Approved: true}},
+		{"decided while waiting", time.Minute, false, 10 * time.Millisecond, &webhook.ApprovalDecision{Approved: true}},
+		{"timeout", 10 * time.Millisecond, false, -1, nil},
+		{"canceled", time.Minute, true, -1, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewApprovalStore()
+			a := &PendingApproval{Method: ApprovalMethodWebhook}
+			s.add(a, tt.timeout)
+
+			ctx, cancel := context.WithCancel(t.Context())
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+
+			if tt.decideAfter >= 0 {
+				time.AfterFunc(tt.decideAfter, func() { _ = s.Decide(a.ID, webhook.ApprovalDecision{Approved: true}) })
+			}
+
+			got := s.wait(ctx, a)
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("wait() = %v, want %v", got, tt.want)
+			}
+
+			if pending := s.Pending(); len(pending) != 0 {
+				t.Errorf("Pending() = %v, want none", pending)
+			}
+
+			if err := s.Decide(a.ID, webhook.ApprovalDecision{Approved: true}); !errors.Is(err, ErrApprovalNotFound) {
+				t.Errorf("Decide() after wait = %v, want %v", err, ErrApprovalNotFound)
+			}
+		})
+	}
+}
+
+func TestApprovalDecideRace(t *testing.T) {
+	for range 100 {
+		s := NewApprovalStore()
+		a := &PendingApproval{Method: ApprovalMethodWebhook}
+		s.add(a, time.Millisecond)
+
+		var decided atomic.Int32
+		var wg sync.WaitGroup
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := s.Decide(a.ID, webhook.ApprovalDecision{Approved: true}); err == nil {
+					decided.Add(1)
+				} else if !errors.Is(err, ErrApprovalNotFound) {
+					t.Errorf("Decide() = %v", err)
+				}
+			}()
+		}
+
+		got := s.wait(t.Context(), a)
+		wg.Wait()
+
+		// a decision is either returned by wait or rejected, it is never lost
+		if n := decided.Load(); n > 1 {
+			t.Fatalf("%v decisions were accepted, want at most 1", n)
+		} else if (got != nil) != (n == 1) {
+			t.Fatalf("wait() = %v, but %v decisions were accepted", got, n)
+		}
+	}
+}
+
+func TestApprovalWebhook(t *testing.T) {
+	tests := []struct {
+		name          string
+		decision      string
+		webhookStatus int
+		webhookHangs  bool
+		wantForwarded bool
+	}{
+		{"approved", `{"approved":true}`, http.StatusOK, false, true},
+		{"denied", `{"approved":false,"reason":"no"}`, http.StatusOK, false, false},
+		{"timeout", "", http.StatusOK, false, false},
+		{"webhook error", `{"approved":true}`, http.StatusInternalServerError, false, false},
+		{"webhook hangs", "", http.StatusOK, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approvals := NewApprovalStore()
+			mux := http.NewServeMux()
+			mux.Handle("POST "+ApprovalCallbackPath+"{id}", approvals.CallbackHandler())
+			callback := httptest.NewServer(mux)
+			t.Cleanup(callback.Close)
+
+			hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var approvalReq webhook.ApprovalRequest
+				if err := json.NewDecoder(r.Body).Decode(&approvalReq); err != nil {
+					t.Error(err)
+				}
+				if tt.webhookHangs {
+					<-r.Context().Done()
+					return
+				}
+				w.WriteHeader(tt.webhookStatus)
+				if tt.decision == "" || tt.webhookStatus != http.StatusOK {
+					return
+				}
+
+				go func() {
+					req, _ := http.NewRequest(http.MethodPost, approvalReq.CallbackURL, strings.NewReader(tt.decision))
+					req.Header.Set(webhook.ApprovalSignatureHeader,
+						webhook.SignApprovalDecision("secret", approvalReq.ID, []byte(tt.decision)))
+					resp, err := http.DefaultClient.Do(req)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+
+					_ = resp.Body.Close()
+					if resp.StatusCode != http.StatusNoContent {
+						t.Errorf("callback status = %v", resp.StatusCode)
+					}
+				}()
+			}))
+			t.Cleanup(hook.Close)
+
+			var forwarded atomic.Int32
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				forwarded.Add(1)
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+			}))
+			t.Cleanup(upstream.Close)
+
+			upstreamURL, _ := url.Parse(upstream.URL)
+			hookURL, _ := url.Parse(hook.URL)
+			callbackURL, _ := url.Parse(callback.URL + ApprovalCallbackPath)
+			cfg := &config.Proxy{
+				Path: "/",
+				Http: &config.ProxyHttp{Url: (*config.URL)(upstreamURL)},
+				Approval: &config.Approval{
+					Tools:          []string{"t"},
+					Webhook:        &config.Webhook{Url: config.URL(*hookURL)},
+					CallbackSecret: "secret",
+					Timeout:        200 * time.Millisecond,
+				},
+			}
+			handler, err := NewProxyHandler(cfg, NewSessionRegistry(), nil, approvals, callbackURL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp := postMessage(handler, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"t","arguments":{}}}`)
+			if got := forwarded.Load() > 0; got != tt.wantForwarded {
+				t.Errorf("forwarded = %v, want %v", got, tt.wantForwarded)
+			} else if !tt.wantForwarded && (resp == nil || resp.Error == nil) {
+				t.Errorf("response = %+v, want error", resp)
+			}
+		})
+	}
+}
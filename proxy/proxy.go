@@ -6,21 +6,62 @@ import (
 	"net/url"
 
 	"github.com/hyprmcp/mcp-gateway/config"
+	"github.com/hyprmcp/mcp-gateway/inspect"
 	"github.com/hyprmcp/mcp-gateway/oauth"
 	"github.com/hyprmcp/mcp-gateway/proxy/proxyutil"
+	"github.com/hyprmcp/mcp-gateway/redact"
+	"github.com/hyprmcp/mcp-gateway/rules"
 )
 
-func NewProxyHandler(config *config.Proxy, modifyResponse func(*http.Response) error) http.Handler {
+func NewProxyHandler(
+	config *config.Proxy,
+	sessions *SessionRegistry,
+	toolPins *ToolPinStore,
+	approvals *ApprovalStore,
+	approvalCallbackURL *url.URL,
+	modifyResponse func(*http.Response) error,
+) (http.Handler, error) {
 	url := (*url.URL)(config.Http.Url)
 
+	redactor, err := redact.New(config.Redaction)
+	if err != nil {
+		return nil, err
+	}
+
+	inspector, err := inspect.New(config.Inspection)
+	if err != nil {
+		return nil, err
+	}
+
+	ruleEngine, err := rules.New(config.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &mcpAwareTransport{
+		config:    config,
+		sessions:  sessions,
+		redactor:  redactor,
+		inspector: inspector,
+		rules:     ruleEngine,
+	}
+	if config.Validation.Enabled() {
+		transport.toolSchemas = newToolSchemaCache()
+	}
+	if config.Approval != nil {
+		transport.approvals = approvals
+		transport.approvalCallbackURL = approvalCallbackURL
+	}
+	if config.ToolPinning != nil && config.ToolPinning.Enabled {
+		transport.toolPins = toolPins
+	}
+
 	return &httputil.ReverseProxy{
 		Rewrite: proxyutil.RewriteChain(
 			proxyutil.RewriteFullFunc(url),
 			oauth.RewriteSetOriginalURL,
 		),
 		ModifyResponse: proxyutil.ModifyResponseChain(modifyResponse, proxyutil.RemoveCORSHeaders),
-		Transport: &mcpAwareTransport{
-			config: config,
-		},
-	}
+		Transport:      transport,
+	}, nil
 }
@@ -0,0 +1,204 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyprmcp/mcp-gateway/log"
+)
+
+const (
+	MCPSessionIDHeader = "Mcp-Session-Id"
+
+	// sessionIdleTimeout is the duration after which a session that has not been used is no longer tracked.
+	sessionIdleTimeout = 24 * time.Hour
+	// sessionPruneInterval is the minimum duration between two prunings of the idle and killed sessions.
+	sessionPruneInterval = time.Minute
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// sessionTerminationClient is used to terminate killed sessions upstream.
+var sessionTerminationClient = &http.Client{Timeout: 10 * time.Second}
+
+type Session struct {
+	ID         string    `json:"id"`
+	Path       string    `json:"path"`
+	Upstream   string    `json:"upstream"`
+	Subject    string    `json:"subject,omitempty"`
+	UserAgent  string    `json:"userAgent,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+}
+
+// SessionRegistry keeps track of the MCP sessions that are proxied by the gateway.
+//
+// A single SessionRegistry should be shared by all proxy handlers, so that sessions survive configuration reloads.
+type SessionRegistry struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+	killed   map[string]time.Time
+	prunedAt time.Time
+}
+
+func NewSessionRegistry() *SessionRegistry {
+	return &SessionRegistry{sessions: map[string]*Session{}, killed: map[string]time.Time{}}
+}
+
+// Touch records that the session was used.
+func (r *SessionRegistry) Touch(id string, path string, upstream string, subject string, userAgent string) {
+	if r == nil || id == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.prune()
+
+	now := time.Now()
+	if s, ok := r.sessions[id]; ok {
+		s.LastSeenAt = now
+	} else {
+		r.sessions[id] = &Session{
+			ID:         id,
+			Path:       path,
+			Upstream:   upstream,
+			Subject:    subject,
+			UserAgent:  userAgent,
+			CreatedAt:  now,
+			LastSeenAt: now,
+		}
+	}
+}
+
+// Remove forgets the session without terminating it.
+func (r *SessionRegistry) Remove(id string) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions, id)
+}
+
+// IsKilled returns true if the session was terminated with Kill.
+func (r *SessionRegistry) IsKilled(id string) bool {
+	if r == nil || id == "" {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.killed[id]
+	return ok
+}
+
+// List returns all sessions that are currently active, ordered by creation time.
+func (r *SessionRegistry) List() []Session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.prune()
+
+	result := make([]Session, 0, len(r.sessions))
+	for _, s := range r.sessions {
+		result = append(result, *s)
+	}
+
+	slices.SortFunc(result, func(a, b Session) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return result
+}
+
+// Kill terminates the session upstream and makes sure that all further requests for this session are rejected, which
+// forces the client to initialize a new session. It returns ErrSessionNotFound if the session is unknown.
+func (r *SessionRegistry) Kill(ctx context.Context, id string) error {
+	r.mu.Lock()
+	s, ok := r.sessions[id]
+	if ok {
+		delete(r.sessions, id)
+		r.prune()
+		r.killed[id] = time.Now()
+	}
+	r.mu.Unlock()
+
+	if !ok {
+		return ErrSessionNotFound
+	}
+
+	// Terminating the session upstream is best effort, because the upstream might require credentials we don't have.
+	// The session is killed anyway, so failures are only logged.
+	if err := terminateUpstream(ctx, s); err != nil {
+		log.Get(ctx).Error(err, "upstream session termination failed", "session", s.ID, "upstream", s.Upstream)
+	}
+
+	return nil
+}
+
+// terminateUpstream terminates the session at the upstream server.
+func terminateUpstream(ctx context.Context, s *Session) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.Upstream, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set(MCPSessionIDHeader, s.ID)
+	if resp, err := sessionTerminationClient.Do(req); err != nil {
+		return err
+	} else {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode >= http.StatusBadRequest && resp.StatusCode != http.StatusMethodNotAllowed {
+			return fmt.Errorf("upstream responded with %v", resp.Status)
+		}
+	}
+
+	return nil
+}
+
+// prune removes the sessions that have been idle and the killed sessions that have been killed for longer than
+// sessionIdleTimeout, at most once per sessionPruneInterval. It must be called with r.mu locked.
+func (r *SessionRegistry) prune() {
+	now := time.Now()
+	if now.Sub(r.prunedAt) < sessionPruneInterval {
+		return
+	}
+
+	r.prunedAt = now
+	threshold := now.Add(-sessionIdleTimeout)
+
+	for id, s := range r.sessions {
+		if s.LastSeenAt.Before(threshold) {
+			delete(r.sessions, id)
+		}
+	}
+
+	for id, t := range r.killed {
+		if t.Before(threshold) {
+			delete(r.killed, id)
+		}
+	}
+}
+
+func sessionNotFoundResponse(req *http.Request) *http.Response {
+	body := "session not found\n"
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", http.StatusNotFound, http.StatusText(http.StatusNotFound)),
+		StatusCode:    http.StatusNotFound,
+		Proto:         req.Proto,
+		ProtoMajor:    req.ProtoMajor,
+		ProtoMinor:    req.ProtoMinor,
+		Header:        http.Header{"Content-Type": []string{"text/plain; charset=utf-8"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
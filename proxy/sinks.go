@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"encoding/json"
+	"expvar"
+	"os"
+	"sync"
+
+	"github.com/hyprmcp/mcp-gateway/webhook"
+)
+
+var (
+	mcpRequestsTotal        = expvar.NewMap("mcp_requests_total")
+	mcpRequestErrorsTotal   = expvar.NewMap("mcp_request_errors_total")
+	mcpRequestDurationTotal = expvar.NewMap("mcp_request_duration_seconds_total")
+	mcpToolCallsTotal       = expvar.NewMap("mcp_tool_calls_total")
+)
+
+// fileSinks contains the open files of the file sinks by path. The files are shared by all proxies and stay open, so
+// that they survive configuration reloads.
+var fileSinks = struct {
+	sync.Mutex
+	files map[string]*os.File
+}{files: map[string]*os.File{}}
+
+// recordMetrics counts the request of the payload. The requests and errors are counted by the path of the proxy and
+// the JSON-RPC method, tool calls by the path of the proxy and the name of the tool.
+func recordMetrics(path string, toolName string, pl webhook.WebhookPayload) {
+	if pl.MCPRequest == nil {
+		return
+	}
+
+	key := path + " " + pl.MCPRequest.Method
+	mcpRequestsTotal.Add(key, 1)
+	mcpRequestDurationTotal.AddFloat(key, pl.Duration.Seconds())
+
+	if pl.HttpError != "" || pl.HttpStatusCode >= 400 || (pl.MCPResponse != nil && pl.MCPResponse.Error != nil) {
+		mcpRequestErrorsTotal.Add(key, 1)
+	}
+
+	if toolName != "" {
+		mcpToolCallsTotal.Add(path+" "+toolName, 1)
+	}
+}
+
+// appendToFile appends the payload as JSON line to the file, which is created if it doesn't exist.
+func appendToFile(path string, pl webhook.WebhookPayload) error {
+	data, err := json.Marshal(pl)
+	if err != nil {
+		return err
+	}
+
+	fileSinks.Lock()
+	defer fileSinks.Unlock()
+
+	f, ok := fileSinks.files[path]
+	if !ok {
+		if f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600); err != nil {
+			return err
+		}
+		fileSinks.files[path] = f
+	}
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
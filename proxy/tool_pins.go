@@ -0,0 +1,244 @@
+package proxy
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/hyprmcp/mcp-gateway/config"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/opencontainers/go-digest"
+)
+
+var ErrToolChangeNotFound = errors.New("tool change not found")
+
+// ToolPin is the digest of a tool definition that was accepted for an upstream server.
+type ToolPin struct {
+	Upstream string        `json:"upstream"`
+	Tool     string        `json:"tool"`
+	Digest   digest.Digest `json:"digest"`
+	PinnedAt time.Time     `json:"pinnedAt"`
+}
+
+// ToolChange is a tool definition that differs from the pinned definition of the tool. For new tools that require an
+// approval, PinnedDigest is empty.
+type ToolChange struct {
+	Upstream     string          `json:"upstream"`
+	Tool         string          `json:"tool"`
+	PinnedDigest digest.Digest   `json:"pinnedDigest,omitempty"`
+	Digest       digest.Digest   `json:"digest"`
+	Definition   json.RawMessage `json:"definition"`
+	DetectedAt   time.Time       `json:"detectedAt"`
+}
+
+// ToolPinStore keeps the pinned tool definitions of all upstream servers and the changes that are waiting for an
+// approval. Tools are identified by the URL of the upstream server and their name.
+//
+// A single ToolPinStore should be shared by all proxy handlers, so that pins survive configuration reloads.
+type ToolPinStore struct {
+	path  string
+	mu    sync.Mutex
+	state toolPinState
+}
+
+type toolPinState struct {
+	Pins    []ToolPin    `json:"pins"`
+	Pending []ToolChange `json:"pending"`
+}
+
+// NewToolPinStore creates the store that is configured in cfg. It is kept in memory unless a store file is configured.
+func NewToolPinStore(cfg *config.ToolPins) (*ToolPinStore, error) {
+	s := &ToolPinStore{}
+	if cfg == nil || cfg.StoreFile == "" {
+		return s, nil
+	}
+
+	s.path = cfg.StoreFile
+	if data, err := os.ReadFile(s.path); errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read tool pin store: %w", err)
+	} else if err := json.Unmarshal(data, &s.state); err != nil {
+		return nil, fmt.Errorf("failed to parse tool pin store %v: %w", s.path, err)
+	}
+
+	return s, nil
+}
+
+// Pins returns all pinned tool definitions.
+func (s *ToolPinStore) Pins() []ToolPin {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := slices.Clone(s.state.Pins)
+	slices.SortFunc(result, func(a, b ToolPin) int {
+		return cmp.Or(cmp.Compare(a.Upstream, b.Upstream), cmp.Compare(a.Tool, b.Tool))
+	})
+	return result
+}
+
+// Pending returns all changes that are waiting for an approval, ordered by the time they were detected.
+func (s *ToolPinStore) Pending() []ToolChange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := slices.Clone(s.state.Pending)
+	slices.SortFunc(result, func(a, b ToolChange) int { return a.DetectedAt.Compare(b.DetectedAt) })
+	return result
+}
+
+// Approve pins the tool definition of a pending change. The digest must match the pending change, so that a definition
+// that changed again after it was reviewed is not approved accidentally.
+func (s *ToolPinStore) Approve(upstream, tool string, d digest.Digest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := s.pendingIndex(upstream, tool); i < 0 || s.state.Pending[i].Digest != d {
+		return ErrToolChangeNotFound
+	}
+
+	s.pin(upstream, tool, d)
+	return s.save()
+}
+
+// check compares the definition of a tool in a tools/list response with its pin. It returns whether the tool may be
+// used and the change, if one was detected that was not reported before.
+func (s *ToolPinStore) check(upstream string, tool *mcp.Tool, action config.ToolPinningAction) (bool, *ToolChange, error) {
+	definition, d, err := toolDigest(tool)
+	if err != nil {
+		return false, nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pinIndex := s.pinIndex(upstream, tool.Name)
+	var pinned digest.Digest
+	if pinIndex >= 0 {
+		pinned = s.state.Pins[pinIndex].Digest
+	}
+
+	if pinned == d {
+		// the definition may have been changed back to the pinned definition
+		if i := s.pendingIndex(upstream, tool.Name); i >= 0 {
+			s.state.Pending = slices.Delete(s.state.Pending, i, i+1)
+			return true, nil, s.save()
+		}
+		return true, nil, nil
+	} else if pinIndex < 0 && action != config.ToolPinningActionApprove {
+		s.pin(upstream, tool.Name, d)
+		return true, nil, s.save()
+	}
+
+	change := &ToolChange{
+		Upstream:     upstream,
+		Tool:         tool.Name,
+		PinnedDigest: pinned,
+		Digest:       d,
+		Definition:   definition,
+		DetectedAt:   time.Now(),
+	}
+
+	if action == config.ToolPinningActionAlert {
+		s.pin(upstream, tool.Name, d)
+		return true, change, s.save()
+	}
+
+	if i := s.pendingIndex(upstream, tool.Name); i >= 0 && s.state.Pending[i].Digest == d {
+		return false, nil, nil
+	} else if i >= 0 {
+		s.state.Pending[i] = *change
+	} else {
+		s.state.Pending = append(s.state.Pending, *change)
+	}
+
+	return false, change, s.save()
+}
+
+// allowed returns whether a tool may be called. Tools are not allowed if they have a pending change or, if approvals
+// are required for all tools, if they are not pinned.
+func (s *ToolPinStore) allowed(upstream, tool string, action config.ToolPinningAction) bool {
+	if action == config.ToolPinningActionAlert {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pendingIndex(upstream, tool) >= 0 {
+		return false
+	} else {
+		return action != config.ToolPinningActionApprove || s.pinIndex(upstream, tool) >= 0
+	}
+}
+
+// pin replaces the pin of the tool and removes its pending change.
+func (s *ToolPinStore) pin(upstream, tool string, d digest.Digest) {
+	pin := ToolPin{Upstream: upstream, Tool: tool, Digest: d, PinnedAt: time.Now()}
+	if i := s.pinIndex(upstream, tool); i >= 0 {
+		s.state.Pins[i] = pin
+	} else {
+		s.state.Pins = append(s.state.Pins, pin)
+	}
+
+	if i := s.pendingIndex(upstream, tool); i >= 0 {
+		s.state.Pending = slices.Delete(s.state.Pending, i, i+1)
+	}
+}
+
+func (s *ToolPinStore) pinIndex(upstream, tool string) int {
+	return slices.IndexFunc(s.state.Pins, func(p ToolPin) bool { return p.Upstream == upstream && p.Tool == tool })
+}
+
+func (s *ToolPinStore) pendingIndex(upstream, tool string) int {
+	return slices.IndexFunc(s.state.Pending, func(c ToolChange) bool { return c.Upstream == upstream && c.Tool == tool })
+}
+
+// save writes the pins to a temporary file which then replaces the store file, so that the store file is never left
+// in a partially written state.
+func (s *ToolPinStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write tool pin store: %w", err)
+	}
+
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write tool pin store: %w", err)
+	} else if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write tool pin store: %w", err)
+	} else if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write tool pin store: %w", err)
+	}
+
+	return nil
+}
+
+// toolDigest returns the definition of the tool and its digest. The _meta field is not part of the definition, because
+// it may contain data that changes between responses.
+func toolDigest(tool *mcp.Tool) (json.RawMessage, digest.Digest, error) {
+	definition := *tool
+	definition.Meta = nil
+	if data, err := json.Marshal(definition); err != nil {
+		return nil, "", fmt.Errorf("failed to marshal tool definition: %w", err)
+	} else {
+		return data, digest.FromBytes(data), nil
+	}
+}
@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// supportedSchemaVersion is the only JSON Schema version that can be validated by jsonschema-go.
+const supportedSchemaVersion = "https://json-schema.org/draft/2020-12/schema"
+
+// toolSchemaCache caches the resolved schemas of the tools that an upstream server returned in tools/list responses.
+// Since the tools of a server may differ between sessions, they are cached per MCP session. Servers without sessions
+// use the empty session ID.
+type toolSchemaCache struct {
+	mu       sync.Mutex
+	sessions map[string]*sessionToolSchemas
+}
+
+type sessionToolSchemas struct {
+	inputSchemas  map[string]*jsonschema.Resolved
+	outputSchemas map[string]*jsonschema.Resolved
+	lastUsed      time.Time
+}
+
+func newToolSchemaCache() *toolSchemaCache {
+	return &toolSchemaCache{sessions: map[string]*sessionToolSchemas{}}
+}
+
+// update caches the schemas of the given tools. If reset is true, all tools that were cached for the session before
+// are removed, otherwise the tools are added to them, e.g. for subsequent pages of the tool list. It returns the names
+// of the tools whose schemas could not be resolved.
+func (c *toolSchemaCache) update(sessionID string, tools []*mcp.Tool, reset bool) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.prune()
+
+	schemas, ok := c.sessions[sessionID]
+	if !ok || reset {
+		schemas = &sessionToolSchemas{
+			inputSchemas:  map[string]*jsonschema.Resolved{},
+			outputSchemas: map[string]*jsonschema.Resolved{},
+		}
+		c.sessions[sessionID] = schemas
+	}
+	schemas.lastUsed = time.Now()
+
+	var unresolved []string
+	for _, tool := range tools {
+		delete(schemas.inputSchemas, tool.Name)
+		delete(schemas.outputSchemas, tool.Name)
+
+		if input, err := resolveToolSchema(tool.InputSchema); err != nil {
+			unresolved = append(unresolved, tool.Name)
+		} else if output, err := resolveToolSchema(tool.OutputSchema); err != nil {
+			unresolved = append(unresolved, tool.Name)
+		} else {
+			if input != nil {
+				schemas.inputSchemas[tool.Name] = input
+			}
+			if output != nil {
+				schemas.outputSchemas[tool.Name] = output
+			}
+		}
+	}
+
+	return unresolved
+}
+
+// inputSchema returns the input schema of the tool or nil if it is unknown.
+func (c *toolSchemaCache) inputSchema(sessionID, toolName string) *jsonschema.Resolved {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if schemas, ok := c.sessions[sessionID]; !ok {
+		return nil
+	} else {
+		schemas.lastUsed = time.Now()
+		return schemas.inputSchemas[toolName]
+	}
+}
+
+// outputSchema returns the output schema of the tool or nil if it is unknown or the tool has none.
+func (c *toolSchemaCache) outputSchema(sessionID, toolName string) *jsonschema.Resolved {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if schemas, ok := c.sessions[sessionID]; !ok {
+		return nil
+	} else {
+		schemas.lastUsed = time.Now()
+		return schemas.outputSchemas[toolName]
+	}
+}
+
+func (c *toolSchemaCache) prune() {
+	threshold := time.Now().Add(-sessionIdleTimeout)
+	for id, schemas := range c.sessions {
+		if schemas.lastUsed.Before(threshold) {
+			delete(c.sessions, id)
+		}
+	}
+}
+
+// resolveToolSchema resolves a copy of the schema for validation, so that later modifications of the tool, such as the
+// injection of telemetry inputs, don't affect it. Schemas that declare an older JSON Schema version, which many servers
+// do, are validated as if they were 2020-12 schemas, because the keywords that are used for tools are compatible.
+func resolveToolSchema(schema *jsonschema.Schema) (*jsonschema.Resolved, error) {
+	if schema == nil {
+		return nil, nil
+	}
+
+	schema = schema.CloneSchemas()
+	if schema.Schema != supportedSchemaVersion {
+		schema.Schema = ""
+	}
+
+	return schema.Resolve(nil)
+}
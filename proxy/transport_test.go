@@ -0,0 +1,330 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hyprmcp/mcp-gateway/config"
+	"github.com/hyprmcp/mcp-gateway/jsonrpc"
+)
+
+// newTestProxy returns a proxy handler for cfg whose upstream server answers every request with an empty result and
+// counts the forwarded requests.
+func newTestProxy(t *testing.T, cfg *config.Proxy) (http.Handler, *atomic.Int32) {
+	t.Helper()
+
+	forwarded := new(atomic.Int32)
+	handler := newTestProxyWithUpstream(t, cfg, func(w http.ResponseWriter, r *http.Request) {
+		forwarded.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	})
+
+	return handler, forwarded
+}
+
+// newTestProxyWithUpstream returns a proxy handler for cfg whose upstream server is handled by upstreamHandler.
+func newTestProxyWithUpstream(t *testing.T, cfg *config.Proxy, upstreamHandler http.HandlerFunc) http.Handler {
+	t.Helper()
+
+	upstream := httptest.NewServer(upstreamHandler)
+	t.Cleanup(upstream.Close)
+
+	u, _ := url.Parse(upstream.URL)
+	cfg.Http = &config.ProxyHttp{Url: (*config.URL)(u)}
+	toolPins, _ := NewToolPinStore(nil)
+	handler, err := NewProxyHandler(cfg, NewSessionRegistry(), toolPins, NewApprovalStore(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return handler
+}
+
+// postMessage posts body to the proxy handler and returns the JSON-RPC response, which is nil if the response is not
+// a JSON-RPC response.
+func postMessage(handler http.Handler, body string) *jsonrpc.Response {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var resp jsonrpc.Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		return nil
+	}
+	return &resp
+}
+
+func TestRejectRequestsThatCanNotBeHandled(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		wantForwarded bool
+		wantCode      int64
+	}{
+		{"valid request", `{"jsonrpc":"2.0","id":1,"method":"ping"}`, true, 0},
+		{"client response", `{"jsonrpc":"2.0","id":"upstream-1","result":{}}`, true, 0},
+		{"invalid JSON", `x{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"t"}}`, false, -32700},
+		{"tools/call with invalid params", `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":[]}`, false, -32602},
+		{"tools/call with invalid name", `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":1}}`, false, -32602},
+		{"batch", `[{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"t"}}]`, false, -32600},
+		{"duplicate method", `{"jsonrpc":"2.0","id":1,"method":"tools/call","method":"ping"}`, false, -32600},
+		{"ambiguous method", `{"jsonrpc":"2.0","id":1,"method":"tools/call","Method":"ping"}`, false, -32600},
+		{"ambiguous tool name", `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"t","Name":"u"}}`, false, -32602},
+		{"ambiguous arguments", `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"t","arguments":{},"argumentſ":{}}}`, false, -32602},
+		{"duplicate argument", `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"t","arguments":{"path":"a","path":"b"}}}`, false, -32602},
+		{"ambiguous argument", `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"t","arguments":{"path":"a","PATH":"b"}}}`, false, -32602},
+		{"nested duplicate argument", `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"t","arguments":{"files":[{"path":"a","path":"b"}]}}}`, false, -32602},
+	}
+
+	policies := map[string]func() *config.Proxy{
+		"validation": func() *config.Proxy {
+			return &config.Proxy{Path: "/", Validation: config.ProxyValidation{ToolArguments: true}}
+		},
+		"tool pinning": func() *config.Proxy {
+			return &config.Proxy{Path: "/", ToolPinning: &config.ToolPinning{Enabled: true}}
+		},
+		"rules": func() *config.Proxy {
+			return &config.Proxy{Path: "/", Rules: []config.Rule{{Name: "all", Match: "true"}}}
+		},
+		"approval": func() *config.Proxy {
+			return &config.Proxy{Path: "/", Approval: &config.Approval{Tools: []string{"other"}, Elicitation: true}}
+		},
+		"inspection": func() *config.Proxy {
+			return &config.Proxy{Path: "/", Inspection: &config.Inspection{Arguments: true}}
+		},
+	}
+
+	for policy, newConfig := range policies {
+		for _, tt := range tests {
+			t.Run(policy+" "+tt.name, func(t *testing.T) {
+				handler, forwarded := newTestProxy(t, newConfig())
+
+				resp := postMessage(handler, tt.body)
+				if got := forwarded.Load() > 0; got != tt.wantForwarded {
+					t.Errorf("forwarded = %v, want %v", got, tt.wantForwarded)
+				}
+
+				if tt.wantCode != 0 && (resp == nil || resp.Error == nil || resp.Error.Code != tt.wantCode) {
+					t.Errorf("response = %+v, want error code %v", resp, tt.wantCode)
+				}
+			})
+		}
+	}
+}
+
+func TestForwardRequestsThatCanNotBeHandledWithoutPolicies(t *testing.T) {
+	handler, forwarded := newTestProxy(t, &config.Proxy{
+		Path:        "/",
+		ToolPinning: &config.ToolPinning{Enabled: true, Action: config.ToolPinningActionAlert},
+	})
+
+	postMessage(handler, `x{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"t"}}`)
+	if forwarded.Load() != 1 {
+		t.Errorf("forwarded %v requests, want 1", forwarded.Load())
+	}
+}
+
+func TestBlockCallsOfUnapprovedTools(t *testing.T) {
+	handler, forwarded := newTestProxy(t, &config.Proxy{
+		Path:        "/",
+		ToolPinning: &config.ToolPinning{Enabled: true, Action: config.ToolPinningActionApprove},
+	})
+
+	resp := postMessage(handler, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"t","arguments":{}}}`)
+	if forwarded.Load() != 0 {
+		t.Errorf("forwarded %v requests, want 0", forwarded.Load())
+	} else if resp == nil || resp.Error == nil || resp.Error.Code != -32602 {
+		t.Errorf("response = %+v, want invalid params error", resp)
+	}
+}
+
+func TestDenyRequestsWhenRulesFail(t *testing.T) {
+	tests := []struct {
+		onError       config.RuleOnError
+		wantForwarded bool
+	}{
+		{"", false},
+		{config.RuleOnErrorDeny, false},
+		{config.RuleOnErrorAllow, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.onError), func(t *testing.T) {
+			handler, forwarded := newTestProxy(t, &config.Proxy{
+				Path:  "/",
+				Rules: []config.Rule{{Name: "limit", Match: "args.limit > 10", OnError: tt.onError}},
+			})
+
+			resp := postMessage(handler, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"t","arguments":{}}}`)
+			if got := forwarded.Load() > 0; got != tt.wantForwarded {
+				t.Errorf("forwarded = %v, want %v", got, tt.wantForwarded)
+			} else if !tt.wantForwarded && (resp == nil || resp.Error == nil) {
+				t.Errorf("response = %+v, want error", resp)
+			}
+		})
+	}
+}
+
+func TestBlockToolResultsThatCanNotBeHandled(t *testing.T) {
+	const result = `{"jsonrpc":"2.0","id":1,"result":{"content":[{"type":"text","text":"SECRET"}]}}`
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		upstream       http.HandlerFunc
+	}{
+		{"json", "", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(result))
+		}},
+		{"json with charset", "", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+			_, _ = w.Write([]byte(result))
+		}},
+		{"gzip requested by client", "gzip", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+				w.Header().Set("Content-Encoding", "gzip")
+				gz := gzip.NewWriter(w)
+				_, _ = gz.Write([]byte(result))
+				_ = gz.Close()
+			} else {
+				_, _ = w.Write([]byte(result))
+			}
+		}},
+		{"unsupported content encoding", "", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Encoding", "br")
+			_, _ = w.Write([]byte(result))
+		}},
+		{"event stream with charset", "", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+			_, _ = w.Write([]byte("id: 1\n\nevent: message\ndata: " + result + "\n\n"))
+		}},
+		{"invalid event", "", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("event: message\ndata: x" + result + "\n\n"))
+		}},
+		{"invalid json", "", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte("x" + result))
+		}},
+		{"unknown content type", "", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte(result))
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newTestProxyWithUpstream(t, &config.Proxy{
+				Path: "/",
+				Inspection: &config.Inspection{Rules: []config.InspectionRule{
+					{Name: "secret", Pattern: "SECRET", Action: config.InspectionActionBlock},
+				}},
+			}, tt.upstream)
+
+			body := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"t","arguments":{}}}`
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept", "application/json, text/event-stream")
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got := rec.Body.String(); strings.Contains(got, "SECRET") || !strings.Contains(got, `"id":1`) {
+				t.Errorf("response = %v, want a blocked result", got)
+			}
+		})
+	}
+}
+
+func TestRejectToolResultsThatCanNotBeValidated(t *testing.T) {
+	const tools = `{"jsonrpc":"2.0","id":1,"result":{"tools":[{"name":"t","inputSchema":{"type":"object"},` +
+		`"outputSchema":{"type":"object","properties":{"count":{"type":"integer"}},"required":["count"]}}]}}`
+	const result = `{"jsonrpc":"2.0","id":2,"result":{"content":[],"structuredContent":{"count":"many"}}}`
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{"json", "application/json", result},
+		{"json with charset", "application/json; charset=UTF-8", result},
+		{"invalid json", "application/json", "x" + result},
+		{"unknown content type", "text/plain", result},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newTestProxyWithUpstream(t, &config.Proxy{
+				Path:       "/",
+				Validation: config.ProxyValidation{ToolResults: config.ToolResultValidationError},
+			}, func(w http.ResponseWriter, r *http.Request) {
+				data, _ := io.ReadAll(r.Body)
+				if strings.Contains(string(data), "tools/list") {
+					w.Header().Set("Content-Type", "application/json")
+					_, _ = w.Write([]byte(tools))
+				} else {
+					w.Header().Set("Content-Type", tt.contentType)
+					_, _ = w.Write([]byte(tt.body))
+				}
+			})
+
+			postMessage(handler, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+			resp := postMessage(handler, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"t","arguments":{}}}`)
+			if resp == nil || resp.ID.Num != 2 {
+				t.Fatalf("response = %+v, want a response with id 2", resp)
+			} else if resp.Error == nil && (resp.Result == nil || !strings.Contains(string(*resp.Result), `"isError":true`)) {
+				t.Errorf("response = %+v, want an error", resp)
+			}
+		})
+	}
+}
+
+func TestDenyResponsesWhenResultRulesCanNotBeApplied(t *testing.T) {
+	tests := []struct {
+		name        string
+		onError     config.RuleOnError
+		contentType string
+		body        string
+		wantError   bool
+	}{
+		{"json with charset", "", "application/json; charset=UTF-8", `{"jsonrpc":"2.0","id":1,"result":{}}`, false},
+		{"invalid json", "", "application/json", `x{"jsonrpc":"2.0","id":1,"result":{}}`, true},
+		{"unknown content type", "", "text/plain", `{"jsonrpc":"2.0","id":1,"result":{}}`, true},
+		{"invalid json allowed", config.RuleOnErrorAllow, "application/json", `x{"jsonrpc":"2.0","id":1,"result":{}}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newTestProxyWithUpstream(t, &config.Proxy{
+				Path:  "/",
+				Rules: []config.Rule{{Name: "r", Match: "true", Result: `{"rewritten": true}`, OnError: tt.onError}},
+			}, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				_, _ = w.Write([]byte(tt.body))
+			})
+
+			resp := postMessage(handler, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"t","arguments":{}}}`)
+			if tt.wantError && (resp == nil || resp.Error == nil) {
+				t.Errorf("response = %+v, want an error", resp)
+			} else if !tt.wantError && resp != nil && resp.Error != nil {
+				t.Errorf("response = %+v, want no error", resp)
+			} else if !tt.wantError && resp != nil && string(*resp.Result) != `{"rewritten":true}` {
+				t.Errorf("result = %s, want the rewritten result", *resp.Result)
+			}
+		})
+	}
+}
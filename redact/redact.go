@@ -0,0 +1,267 @@
+// Package redact removes sensitive data from MCP messages before they are logged or sent to webhooks.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hyprmcp/mcp-gateway/config"
+	"github.com/hyprmcp/mcp-gateway/webhook"
+)
+
+// Replacement replaces all redacted values. It is the same marker that replaces secrets in the configuration.
+const Replacement = config.Redacted
+
+type pattern struct {
+	re *regexp.Regexp
+	// valid reports whether a match must be redacted. All matches are redacted if it is nil.
+	valid func(match string) bool
+}
+
+var detectors = map[string]pattern{
+	"email": {re: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)},
+	"apiKey": {re: regexp.MustCompile(strings.Join([]string{
+		`\b(?:sk|pk|rk)-[A-Za-z0-9_-]{16,}`,                      // OpenAI, Anthropic and others
+		`\b(?:sk|pk|rk)_(?:live|test)_[A-Za-z0-9]{16,}`,          // Stripe
+		`\bAKIA[0-9A-Z]{16}\b`,                                   // AWS access key IDs
+		`\bgh[pousr]_[A-Za-z0-9]{36,}\b`,                         // GitHub
+		`\bxox[abprs]-[A-Za-z0-9-]{10,}`,                         // Slack
+		`\bAIza[0-9A-Za-z_-]{35}`,                                // Google
+		`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`, // JWTs
+		`(?i)\bbearer\s+[A-Za-z0-9._~+/-]{16,}=*`,
+	}, "|"))},
+	"creditCard": {re: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`), valid: luhn},
+}
+
+// Redactor redacts the MCP messages in webhook payloads. A nil Redactor doesn't change payloads.
+type Redactor struct {
+	paths       [][]string
+	patterns    []pattern
+	maxBodySize int
+	drop        bool
+}
+
+// New creates a Redactor for the given configuration. It returns nil if cfg is nil.
+func New(cfg *config.Redaction) (*Redactor, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	r := &Redactor{maxBodySize: cfg.MaxBodySize, drop: cfg.DropOversizedBodies}
+
+	for _, path := range cfg.Paths {
+		r.paths = append(r.paths, strings.Split(path, "."))
+	}
+
+	for _, name := range cfg.Detectors {
+		if p, ok := detectors[name]; !ok {
+			return nil, fmt.Errorf("unknown redaction detector %q", name)
+		} else {
+			r.patterns = append(r.patterns, p)
+		}
+	}
+
+	for _, expr := range cfg.Patterns {
+		if re, err := regexp.Compile(expr); err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern: %w", err)
+		} else {
+			r.patterns = append(r.patterns, pattern{re: re})
+		}
+	}
+
+	return r, nil
+}
+
+// Payload returns a copy of the payload in which the MCP request and response and the captured telemetry values are
+// redacted. The original messages are not modified.
+func (r *Redactor) Payload(pl webhook.WebhookPayload) webhook.WebhookPayload {
+	if r == nil {
+		return pl
+	}
+
+	if pl.MCPRequest != nil {
+		req := *pl.MCPRequest
+		req.Params = r.body(&pl, req.Params, "params")
+		pl.MCPRequest = &req
+	}
+
+	if pl.MCPResponse != nil {
+		resp := *pl.MCPResponse
+		resp.Result = r.body(&pl, resp.Result, "result")
+		if resp.Error != nil {
+			rpcErr := *resp.Error
+			rpcErr.Message = r.String(rpcErr.Message)
+			rpcErr.Data = r.body(&pl, rpcErr.Data, "error", "data")
+			resp.Error = &rpcErr
+		}
+		pl.MCPResponse = &resp
+	}
+
+	pl.ToolResultValidationError = r.String(pl.ToolResultValidationError)
+	pl.Prompt = r.String(pl.Prompt)
+	pl.History = r.String(pl.History)
+
+	if pl.Telemetry != nil {
+		telemetry := make(map[string]string, len(pl.Telemetry))
+		for name, value := range pl.Telemetry {
+			telemetry[name] = r.String(value)
+		}
+		pl.Telemetry = telemetry
+	}
+
+	return pl
+}
+
+// Arguments returns a copy of the arguments of a tools/call request in which the paths below params.arguments and all
+// matches of the patterns are redacted.
+func (r *Redactor) Arguments(args any) any {
+	if r == nil || args == nil {
+		return args
+	}
+
+	data, err := json.Marshal(args)
+	if err != nil {
+		return Replacement
+	}
+
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return Replacement
+	}
+
+	prefix := []string{"params", "arguments"}
+	for _, path := range r.paths {
+		if len(path) >= len(prefix) && slices.Equal(path[:len(prefix)], prefix) {
+			v = redactPath(v, path[len(prefix):])
+		}
+	}
+
+	return r.value(v)
+}
+
+// String replaces all matches of the patterns in s.
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+
+	for _, p := range r.patterns {
+		if p.valid == nil {
+			s = p.re.ReplaceAllString(s, Replacement)
+		} else {
+			s = p.re.ReplaceAllStringFunc(s, func(match string) string {
+				if p.valid(match) {
+					return Replacement
+				}
+				return match
+			})
+		}
+	}
+
+	return s
+}
+
+// body redacts a body of a JSON-RPC message, whose location in the message is given by prefix, and enforces the size
+// limit.
+func (r *Redactor) body(pl *webhook.WebhookPayload, raw *json.RawMessage, prefix ...string) *json.RawMessage {
+	if raw == nil {
+		return nil
+	}
+
+	data := []byte(*raw)
+
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err == nil {
+		for _, path := range r.paths {
+			if len(path) >= len(prefix) && slices.Equal(path[:len(prefix)], prefix) {
+				v = redactPath(v, path[len(prefix):])
+			}
+		}
+
+		if redacted, err := json.Marshal(r.value(v)); err == nil {
+			data = redacted
+		}
+	}
+
+	if r.maxBodySize > 0 && len(data) > r.maxBodySize {
+		pl.OversizedBodies = true
+		if r.drop {
+			return nil
+		}
+
+		truncated, _ := json.Marshal(strings.ToValidUTF8(string(data[:r.maxBodySize]), "") + "...")
+		data = truncated
+	}
+
+	return (*json.RawMessage)(&data)
+}
+
+// value replaces all matches of the patterns in the strings of a decoded JSON value.
+func (r *Redactor) value(v any) any {
+	switch v := v.(type) {
+	case string:
+		return r.String(v)
+	case map[string]any:
+		for key, elem := range v {
+			v[key] = r.value(elem)
+		}
+	case []any:
+		for i, elem := range v {
+			v[i] = r.value(elem)
+		}
+	}
+	return v
+}
+
+// redactPath replaces the values at the path in a decoded JSON value.
+func redactPath(v any, path []string) any {
+	if len(path) == 0 {
+		return Replacement
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for key, elem := range v {
+			if path[0] == "*" || path[0] == key {
+				v[key] = redactPath(elem, path[1:])
+			}
+		}
+	case []any:
+		for i, elem := range v {
+			if path[0] == "*" || path[0] == strconv.Itoa(i) {
+				v[i] = redactPath(elem, path[1:])
+			}
+		}
+	}
+	return v
+}
+
+// luhn reports whether the digits in s have a valid Luhn checksum, which all credit card numbers have.
+func luhn(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] < '0' || s[i] > '9' {
+			continue
+		}
+
+		d := int(s[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
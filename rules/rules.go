@@ -0,0 +1,337 @@
+// Package rules transforms MCP messages with rules whose conditions and values are CEL expressions.
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/hyprmcp/mcp-gateway/config"
+	"github.com/hyprmcp/mcp-gateway/jsonrpc"
+	"github.com/sourcegraph/jsonrpc2"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Engine applies the rules of a proxy. A nil Engine doesn't change any messages.
+type Engine struct {
+	rules []*rule
+}
+
+type rule struct {
+	name             string
+	match            cel.Program
+	setArguments     map[string]cel.Program
+	defaultArguments map[string]cel.Program
+	removeArguments  []string
+	result           cel.Program
+	err              *jsonrpc2.Error
+	onError          config.RuleOnError
+}
+
+var env = func() *cel.Env {
+	env, err := cel.NewEnv(
+		cel.Variable("method", cel.StringType),
+		cel.Variable("tool", cel.StringType),
+		cel.Variable("args", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("params", cel.DynType),
+		cel.Variable("claims", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("result", cel.DynType),
+	)
+	if err != nil {
+		panic(err)
+	}
+	return env
+}()
+
+// New compiles the rules. It returns nil if there are no rules.
+func New(cfgs []config.Rule) (*Engine, error) {
+	if len(cfgs) == 0 {
+		return nil, nil
+	}
+
+	e := &Engine{}
+	for _, cfg := range cfgs {
+		if r, err := compileRule(cfg); err != nil {
+			return nil, fmt.Errorf("rule %v: %w", cfg.Name, err)
+		} else {
+			e.rules = append(e.rules, r)
+		}
+	}
+	return e, nil
+}
+
+// DeniesUnhandledResponses returns true if any rule rewrites results and denies responses whose result it can't
+// evaluate. Responses that can't be handled at all must not be forwarded then.
+func (e *Engine) DeniesUnhandledResponses() bool {
+	return e != nil && slices.ContainsFunc(e.rules, func(r *rule) bool {
+		return r.result != nil && r.onError != config.RuleOnErrorAllow
+	})
+}
+
+func compileRule(cfg config.Rule) (*rule, error) {
+	r := &rule{
+		name:             cfg.Name,
+		setArguments:     map[string]cel.Program{},
+		defaultArguments: map[string]cel.Program{},
+		removeArguments:  cfg.RemoveArguments,
+		onError:          cfg.GetOnError(),
+	}
+
+	var err error
+	if r.match, err = compile(cfg.Match, true); err != nil {
+		return nil, fmt.Errorf("match: %w", err)
+	}
+
+	for name, expr := range cfg.SetArguments {
+		if r.setArguments[name], err = compile(expr, false); err != nil {
+			return nil, fmt.Errorf("setArguments.%v: %w", name, err)
+		}
+	}
+
+	for name, expr := range cfg.DefaultArguments {
+		if r.defaultArguments[name], err = compile(expr, false); err != nil {
+			return nil, fmt.Errorf("defaultArguments.%v: %w", name, err)
+		}
+	}
+
+	if cfg.Result != "" {
+		if r.result, err = compile(cfg.Result, false); err != nil {
+			return nil, fmt.Errorf("result: %w", err)
+		}
+	}
+
+	if cfg.Error != nil {
+		r.err = &jsonrpc2.Error{Code: cfg.Error.Code, Message: cfg.Error.Message}
+		if r.err.Code == 0 {
+			r.err.Code = jsonrpc2.CodeInvalidRequest
+		}
+	}
+
+	return r, nil
+}
+
+func compile(expr string, isBool bool) (cel.Program, error) {
+	ast, iss := env.Compile(expr)
+	if iss.Err() != nil {
+		return nil, iss.Err()
+	} else if isBool && ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		return nil, fmt.Errorf("expression must evaluate to a bool, not %v", ast.OutputType())
+	}
+	return env.Program(ast)
+}
+
+// Evaluation is the result of applying the rules to a request.
+type Evaluation struct {
+	// Matched contains the names of the rules that matched the request.
+	Matched []string
+	// Request is the request with the changed arguments. It is the original request if no arguments were changed.
+	Request *jsonrpc.Request
+	// Error is set if a rule answers the request with an error. The request must not be forwarded then.
+	Error *jsonrpc2.Error
+
+	vars        map[string]any
+	resultRules []*rule
+}
+
+// ApplyRequest evaluates the rules for a request and applies their argument changes. Errors of expressions are returned
+// in addition to the evaluation, which already reflects whether the rule denies the request because of them. The
+// evaluation is nil if the request can't be evaluated at all.
+func (e *Engine) ApplyRequest(req *jsonrpc.Request, claims map[string]any) (*Evaluation, error) {
+	ev := &Evaluation{Request: req}
+	if e == nil {
+		return ev, nil
+	}
+
+	var params any
+	if req.Params != nil {
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			return nil, fmt.Errorf("params unmarshal error: %w", err)
+		}
+	}
+
+	var tool string
+	args := map[string]any{}
+	paramsMap, isToolCall := params.(map[string]any)
+	isToolCall = isToolCall && req.Method == "tools/call"
+	if isToolCall {
+		tool, _ = paramsMap["name"].(string)
+		if a, ok := paramsMap["arguments"].(map[string]any); ok {
+			args = a
+		}
+	}
+
+	if claims == nil {
+		claims = map[string]any{}
+	}
+
+	ev.vars = map[string]any{
+		"method": req.Method,
+		"tool":   tool,
+		"args":   args,
+		"params": params,
+		"claims": claims,
+	}
+
+	var errs []error
+	argsChanged := false
+	for _, r := range e.rules {
+		if matched, err := evalBool(r.match, ev.vars); err != nil {
+			errs = append(errs, fmt.Errorf("rule %v: match: %w", r.name, err))
+			if ev.deny(r) {
+				return ev, errors.Join(errs...)
+			}
+			continue
+		} else if !matched {
+			continue
+		}
+
+		ev.Matched = append(ev.Matched, r.name)
+
+		if r.err != nil {
+			ev.Error = r.err
+			return ev, errors.Join(errs...)
+		}
+
+		if isToolCall {
+			for _, name := range r.removeArguments {
+				if _, ok := args[name]; ok {
+					delete(args, name)
+					argsChanged = true
+				}
+			}
+
+			for _, name := range slices.Sorted(maps.Keys(r.setArguments)) {
+				if value, err := eval(r.setArguments[name], ev.vars); err != nil {
+					errs = append(errs, fmt.Errorf("rule %v: setArguments.%v: %w", r.name, name, err))
+					if ev.deny(r) {
+						return ev, errors.Join(errs...)
+					}
+				} else {
+					args[name] = value
+					argsChanged = true
+				}
+			}
+
+			for _, name := range slices.Sorted(maps.Keys(r.defaultArguments)) {
+				if _, ok := args[name]; ok {
+					continue
+				} else if value, err := eval(r.defaultArguments[name], ev.vars); err != nil {
+					errs = append(errs, fmt.Errorf("rule %v: defaultArguments.%v: %w", r.name, name, err))
+					if ev.deny(r) {
+						return ev, errors.Join(errs...)
+					}
+				} else {
+					args[name] = value
+					argsChanged = true
+				}
+			}
+		}
+
+		if r.result != nil {
+			ev.resultRules = append(ev.resultRules, r)
+		}
+	}
+
+	if argsChanged {
+		paramsMap["arguments"] = args
+		if data, err := json.Marshal(paramsMap); err != nil {
+			return nil, fmt.Errorf("params marshal error: %w", err)
+		} else {
+			newReq := *req
+			newReq.Params = (*json.RawMessage)(&data)
+			ev.Request = &newReq
+		}
+	}
+
+	return ev, errors.Join(errs...)
+}
+
+// deny sets the error of the evaluation if the rule denies requests whose expressions can't be evaluated and returns
+// true if it does.
+func (ev *Evaluation) deny(r *rule) bool {
+	if r.onError == config.RuleOnErrorAllow {
+		return false
+	}
+
+	ev.Error = &jsonrpc2.Error{
+		Code:    jsonrpc2.CodeInvalidRequest,
+		Message: fmt.Sprintf("The request was denied because rule %v could not be evaluated", r.name),
+	}
+	return true
+}
+
+// ApplyResponse rewrites the result of the response with the rules that matched the request. It returns the original
+// response if no result was rewritten. Errors of expressions are returned in addition to the response, which is an
+// error response if a rule denies responses whose result can't be evaluated. The response is nil if the result can't
+// be rewritten at all.
+func (ev *Evaluation) ApplyResponse(resp *jsonrpc.Response) (*jsonrpc.Response, error) {
+	if len(ev.resultRules) == 0 || resp.Result == nil {
+		return resp, nil
+	}
+
+	var result any
+	if err := json.Unmarshal(*resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("result unmarshal error: %w", err)
+	}
+
+	var errs []error
+	rewritten := false
+	vars := maps.Clone(ev.vars)
+	for _, r := range ev.resultRules {
+		vars["result"] = result
+		if value, err := eval(r.result, vars); err != nil {
+			errs = append(errs, fmt.Errorf("rule %v: result: %w", r.name, err))
+			if r.onError != config.RuleOnErrorAllow {
+				return &jsonrpc.Response{ID: resp.ID, Meta: resp.Meta, Error: &jsonrpc2.Error{
+					Code:    jsonrpc2.CodeInternalError,
+					Message: fmt.Sprintf("The response was denied because rule %v could not be evaluated", r.name),
+				}}, errors.Join(errs...)
+			}
+		} else {
+			result = value
+			rewritten = true
+		}
+	}
+
+	if !rewritten {
+		return resp, errors.Join(errs...)
+	} else if data, err := json.Marshal(result); err != nil {
+		return nil, fmt.Errorf("result marshal error: %w", err)
+	} else {
+		newResp := *resp
+		newResp.Result = (*json.RawMessage)(&data)
+		return &newResp, errors.Join(errs...)
+	}
+}
+
+func evalBool(prg cel.Program, vars map[string]any) (bool, error) {
+	if out, _, err := prg.Eval(vars); err != nil {
+		return false, err
+	} else if b, ok := out.Value().(bool); !ok {
+		return false, fmt.Errorf("expression evaluated to %v instead of a bool", out.Type())
+	} else {
+		return b, nil
+	}
+}
+
+// eval evaluates the expression and converts its value to a JSON value.
+func eval(prg cel.Program, vars map[string]any) (any, error) {
+	if out, _, err := prg.Eval(vars); err != nil {
+		return nil, err
+	} else {
+		return toJSON(out)
+	}
+}
+
+func toJSON(v ref.Val) (any, error) {
+	if pb, err := v.ConvertToNative(reflect.TypeFor[*structpb.Value]()); err != nil {
+		return nil, err
+	} else {
+		return pb.(*structpb.Value).AsInterface(), nil
+	}
+}
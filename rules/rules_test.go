@@ -0,0 +1,268 @@
+package rules
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyprmcp/mcp-gateway/config"
+	"github.com/hyprmcp/mcp-gateway/jsonrpc"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    config.Rule
+		wantErr bool
+	}{
+		{"valid", config.Rule{Match: `tool == "search"`, SetArguments: map[string]string{"limit": "10"}}, false},
+		{"syntax error", config.Rule{Match: `tool ==`}, true},
+		{"unknown variable", config.Rule{Match: `user == "jane"`}, true},
+		{"match is not a bool", config.Rule{Match: `tool`}, true},
+		{"invalid argument", config.Rule{Match: "true", SetArguments: map[string]string{"limit": "1 +"}}, true},
+		{"invalid result", config.Rule{Match: "true", Result: "result."}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New([]config.Rule{tt.rule}); (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		rules       []config.Rule
+		params      string
+		wantParams  string
+		wantMatched []string
+		wantCode    int64
+		wantErr     bool
+	}{
+		{
+			name:        "no match",
+			rules:       []config.Rule{{Name: "r", Match: `tool == "other"`, SetArguments: map[string]string{"limit": "10"}}},
+			params:      `{"name":"search","arguments":{"query":"q"}}`,
+			wantParams:  `{"name":"search","arguments":{"query":"q"}}`,
+			wantMatched: nil,
+		},
+		{
+			name: "argument changes",
+			rules: []config.Rule{{
+				Name:             "r",
+				Match:            `tool == "search"`,
+				SetArguments:     map[string]string{"user": "claims.sub"},
+				DefaultArguments: map[string]string{"limit": "10", "query": `"default"`},
+				RemoveArguments:  []string{"debug"},
+			}},
+			params:      `{"name":"search","arguments":{"query":"q","debug":true}}`,
+			wantParams:  `{"arguments":{"limit":10,"query":"q","user":"jane"},"name":"search"}`,
+			wantMatched: []string{"r"},
+		},
+		{
+			name: "error",
+			rules: []config.Rule{
+				{Name: "deny", Match: `args.query.contains("secret")`, Error: &config.RuleError{Message: "denied"}},
+				{Name: "later", Match: "true"},
+			},
+			params:      `{"name":"search","arguments":{"query":"secret"}}`,
+			wantParams:  `{"name":"search","arguments":{"query":"secret"}}`,
+			wantMatched: []string{"deny"},
+			wantCode:    jsonrpc2.CodeInvalidRequest,
+		},
+		{
+			name:        "match fails",
+			rules:       []config.Rule{{Name: "r", Match: "args.limit > 10"}},
+			params:      `{"name":"search","arguments":{}}`,
+			wantParams:  `{"name":"search","arguments":{}}`,
+			wantMatched: nil,
+			wantCode:    jsonrpc2.CodeInvalidRequest,
+			wantErr:     true,
+		},
+		{
+			name:        "match fails with type error",
+			rules:       []config.Rule{{Name: "r", Match: `args.limit > 10`, OnError: config.RuleOnErrorDeny}},
+			params:      `{"name":"search","arguments":{"limit":"ten"}}`,
+			wantParams:  `{"name":"search","arguments":{"limit":"ten"}}`,
+			wantMatched: nil,
+			wantCode:    jsonrpc2.CodeInvalidRequest,
+			wantErr:     true,
+		},
+		{
+			name: "match fails and is allowed",
+			rules: []config.Rule{
+				{Name: "r", Match: "args.limit > 10", OnError: config.RuleOnErrorAllow, Error: &config.RuleError{Message: "denied"}},
+				{Name: "later", Match: "true"},
+			},
+			params:      `{"name":"search","arguments":{}}`,
+			wantParams:  `{"name":"search","arguments":{}}`,
+			wantMatched: []string{"later"},
+			wantErr:     true,
+		},
+		{
+			name:        "argument fails",
+			rules:       []config.Rule{{Name: "r", Match: "true", SetArguments: map[string]string{"tenant": "claims.tenant"}}},
+			params:      `{"name":"search","arguments":{}}`,
+			wantParams:  `{"name":"search","arguments":{}}`,
+			wantMatched: []string{"r"},
+			wantCode:    jsonrpc2.CodeInvalidRequest,
+			wantErr:     true,
+		},
+		{
+			name: "argument fails and is allowed",
+			rules: []config.Rule{{
+				Name:         "r",
+				Match:        "true",
+				SetArguments: map[string]string{"tenant": "claims.tenant", "user": "claims.sub"},
+				OnError:      config.RuleOnErrorAllow,
+			}},
+			params:      `{"name":"search","arguments":{}}`,
+			wantParams:  `{"arguments":{"user":"jane"},"name":"search"}`,
+			wantMatched: []string{"r"},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := New(tt.rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			params := json.RawMessage(tt.params)
+			ev, err := e.ApplyRequest(&jsonrpc.Request{Method: "tools/call", Params: &params}, map[string]any{"sub": "jane"})
+			if ev == nil {
+				t.Fatalf("ApplyRequest() = nil, %v", err)
+			} else if (err != nil) != tt.wantErr {
+				t.Errorf("ApplyRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got := string(*ev.Request.Params); got != tt.wantParams {
+				t.Errorf("params = %v, want %v", got, tt.wantParams)
+			}
+			if len(ev.Matched) != len(tt.wantMatched) || (len(ev.Matched) > 0 && ev.Matched[0] != tt.wantMatched[0]) {
+				t.Errorf("matched = %v, want %v", ev.Matched, tt.wantMatched)
+			}
+			if tt.wantCode == 0 && ev.Error != nil {
+				t.Errorf("error = %v, want nil", ev.Error)
+			} else if tt.wantCode != 0 && (ev.Error == nil || ev.Error.Code != tt.wantCode) {
+				t.Errorf("error = %v, want code %v", ev.Error, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestApplyRequestWithInvalidParams(t *testing.T) {
+	e, err := New([]config.Rule{{Name: "r", Match: "true"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := json.RawMessage(`{"name":`)
+	if ev, err := e.ApplyRequest(&jsonrpc.Request{Method: "tools/call", Params: &params}, nil); ev != nil || err == nil {
+		t.Errorf("ApplyRequest() = %v, %v, want an error", ev, err)
+	}
+}
+
+func TestApplyResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		rules      []config.Rule
+		wantResult string
+		wantCode   int64
+		wantErr    bool
+	}{
+		{
+			name:       "no result rule",
+			rules:      []config.Rule{{Name: "r", Match: "true"}},
+			wantResult: `{"content":[{"type":"text","text":"hello"}]}`,
+		},
+		{
+			name:       "result",
+			rules:      []config.Rule{{Name: "r", Match: "true", Result: `{"content": [{"type": "text", "text": tool}]}`}},
+			wantResult: `{"content":[{"text":"search","type":"text"}]}`,
+		},
+		{
+			name:     "result fails",
+			rules:    []config.Rule{{Name: "r", Match: "true", Result: "result.missing"}},
+			wantCode: jsonrpc2.CodeInternalError,
+			wantErr:  true,
+		},
+		{
+			name: "result fails and is allowed",
+			rules: []config.Rule{
+				{Name: "r", Match: "true", Result: "result.missing", OnError: config.RuleOnErrorAllow},
+				{Name: "s", Match: "true", Result: `{"isError": false}`},
+			},
+			wantResult: `{"isError":false}`,
+			wantErr:    true,
+		},
+		{
+			name:       "only failing result is allowed",
+			rules:      []config.Rule{{Name: "r", Match: "true", Result: "result.missing", OnError: config.RuleOnErrorAllow}},
+			wantResult: `{"content":[{"type":"text","text":"hello"}]}`,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := New(tt.rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			params := json.RawMessage(`{"name":"search","arguments":{}}`)
+			ev, err := e.ApplyRequest(&jsonrpc.Request{Method: "tools/call", Params: &params}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result := json.RawMessage(`{"content":[{"type":"text","text":"hello"}]}`)
+			resp, err := ev.ApplyResponse(&jsonrpc.Response{ID: jsonrpc2.ID{Num: 1}, Result: &result})
+			if resp == nil {
+				t.Fatalf("ApplyResponse() = nil, %v", err)
+			} else if (err != nil) != tt.wantErr {
+				t.Errorf("ApplyResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantCode != 0 {
+				if resp.Error == nil || resp.Error.Code != tt.wantCode || resp.Result != nil || resp.ID.Num != 1 {
+					t.Errorf("response = %+v, want error code %v", resp, tt.wantCode)
+				}
+			} else if resp.Result == nil || string(*resp.Result) != tt.wantResult {
+				t.Errorf("response = %+v, want result %v", resp, tt.wantResult)
+			}
+		})
+	}
+}
+
+func TestDeniesUnhandledResponses(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []config.Rule
+		want  bool
+	}{
+		{"no rules", nil, false},
+		{"no result rule", []config.Rule{{Name: "r", Match: "true"}}, false},
+		{"result rule", []config.Rule{{Name: "r", Match: "true", Result: "result"}}, true},
+		{"allowed result rule", []config.Rule{{Name: "r", Match: "true", Result: "result", OnError: config.RuleOnErrorAllow}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := New(tt.rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := e.DeniesUnhandledResponses(); got != tt.want {
+				t.Errorf("DeniesUnhandledResponses() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
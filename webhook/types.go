@@ -19,4 +19,77 @@ type WebhookPayload struct {
 	UserAgent       string            `json:"userAgent"`
 	HttpStatusCode  int               `json:"httpStatusCode,omitempty"`
 	HttpError       string            `json:"httpError,omitempty"`
+	// Prompt and History are the prompt and the chat history that led to a tools/call request, as captured by the
+	// telemetry fields. The fields are removed from the arguments in MCPRequest.
+	Prompt  string `json:"prompt,omitempty"`
+	History string `json:"history,omitempty"`
+	// Telemetry contains the values of the other telemetry fields by name.
+	Telemetry map[string]string `json:"telemetry,omitempty"`
+	// ToolResultValidationError is set if the structuredContent of a tool result does not match the output schema of
+	// the tool.
+	ToolResultValidationError string `json:"toolResultValidationError,omitempty"`
+	// ToolDefinitionChanges lists the tools in a tools/list response whose definitions differ from their pinned
+	// definitions. Each change is only reported once.
+	ToolDefinitionChanges []ToolDefinitionChange `json:"toolDefinitionChanges,omitempty"`
+	// OversizedBodies is true if bodies of the MCP messages exceeded the configured size limit and were truncated or
+	// dropped.
+	OversizedBodies bool `json:"oversizedBodies,omitempty"`
+	// Inspections contains the results of the content inspection of tool arguments and results.
+	Inspections []Inspection `json:"inspections,omitempty"`
+	// MatchedRules contains the names of the rules that matched the request.
+	MatchedRules []string `json:"matchedRules,omitempty"`
+	// Approval is the outcome of the approval of a tools/call request that required one.
+	Approval *ApprovalOutcome `json:"approval,omitempty"`
+}
+
+type ApprovalOutcome struct {
+	ID string `json:"id"`
+	// Method is either webhook or elicitation.
+	Method string `json:"method"`
+	// Decision is either approved, denied or timeout.
+	Decision string `json:"decision"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// ApprovalRequest is sent to the approval webhook when a tools/call request requires an approval. The request is
+// approved or denied by POSTing an ApprovalDecision to the CallbackURL before ExpiresAt, signed with
+// SignApprovalDecision in the ApprovalSignatureHeader.
+type ApprovalRequest struct {
+	ID           string    `json:"id"`
+	CallbackURL  string    `json:"callbackUrl"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	Path         string    `json:"path"`
+	Tool         string    `json:"tool"`
+	Arguments    any       `json:"arguments,omitempty"`
+	Subject      string    `json:"subject"`
+	SubjectEmail string    `json:"subjectEmail"`
+	MCPSessionID string    `json:"mcpSessionId"`
+	UserAgent    string    `json:"userAgent"`
+}
+
+type ApprovalDecision struct {
+	Approved bool   `json:"approved"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+type Inspection struct {
+	// Target is either toolArguments or toolResult.
+	Target   string              `json:"target"`
+	Action   string              `json:"action"`
+	Findings []InspectionFinding `json:"findings,omitempty"`
+}
+
+type InspectionFinding struct {
+	// Scanner is the name of the scanner, e.g. the name of a rule, that reported the finding.
+	Scanner string `json:"scanner"`
+	Message string `json:"message,omitempty"`
+}
+
+type ToolDefinitionChange struct {
+	Tool string `json:"tool"`
+	// PinnedDigest is the digest of the pinned definition. It is empty for new tools that require an approval.
+	PinnedDigest digest.Digest `json:"pinnedDigest,omitempty"`
+	Digest       digest.Digest `json:"digest"`
+	// Blocked is true if the tool is not available until the change is approved.
+	Blocked bool `json:"blocked"`
 }
@@ -3,12 +3,37 @@ package webhook
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"sync/atomic"
+	"time"
 )
 
-func Send(ctx context.Context, method string, url string, payload WebhookPayload) error {
+// ApprovalSignatureHeader is the header that contains the signature of an approval decision.
+const ApprovalSignatureHeader = "X-Approval-Signature"
+
+// inFlight counts the deliveries that have been started but not completed. Deliveries are not queued, each one is sent
+// right away.
+var inFlight atomic.Int64
+
+// client is used for all deliveries, so that an endpoint that doesn't respond can't hold a delivery open forever.
+var client = &http.Client{Timeout: 30 * time.Second}
+
+// InFlight returns the number of webhook deliveries that are currently in flight.
+func InFlight() int64 {
+	return inFlight.Load()
+}
+
+// Send sends the payload, usually a WebhookPayload or an ApprovalRequest, as JSON to the URL.
+func Send(ctx context.Context, method string, url string, payload any) error {
+	inFlight.Add(1)
+	defer inFlight.Add(-1)
+
 	if method == "" {
 		method = http.MethodPost
 	}
@@ -17,13 +42,30 @@ func Send(ctx context.Context, method string, url string, payload WebhookPayload
 		return err
 	}
 
-	if req, err := http.NewRequestWithContext(ctx, method, url, &buf); err != nil {
+	req, err := http.NewRequestWithContext(ctx, method, url, &buf)
+	if err != nil {
 		return err
-	} else if resp, err := http.DefaultClient.Do(req); err != nil {
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
 		return err
-	} else if resp.StatusCode >= http.StatusBadRequest {
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+	// the body is drained, so that the connection can be reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= http.StatusBadRequest {
 		return fmt.Errorf("unexpected http status: %v", resp.Status)
-	} else {
-		return nil
 	}
+	return nil
+}
+
+// SignApprovalDecision returns the signature of the body of an approval decision for the approval with the given ID.
+func SignApprovalDecision(secret string, id string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(id + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
 }